@@ -0,0 +1,78 @@
+package pkg
+
+import "context"
+
+// BlockingQueue 有界阻塞队列
+// 队列满时 Enqueue 阻塞，队列空时 Dequeue 阻塞，直到条件满足或者 ctx 结束
+// 适合用作生产者和消费者之间的背压缓冲
+type BlockingQueue[T any] struct {
+	items chan T
+}
+
+// NewBlockingQueue 创建一个容量为 capacity 的阻塞队列
+// capacity 小于等于 0 时按 1 处理
+func NewBlockingQueue[T any](capacity int) *BlockingQueue[T] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &BlockingQueue[T]{
+		items: make(chan T, capacity),
+	}
+}
+
+// Enqueue 入队，队列满时阻塞直到有空位或者 ctx 结束
+func (q *BlockingQueue[T]) Enqueue(ctx context.Context, v T) error {
+	// 先检查一下 ctx，避免 ctx 已经结束了还能入队
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case q.items <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dequeue 出队，队列空时阻塞直到有元素或者 ctx 结束
+func (q *BlockingQueue[T]) Dequeue(ctx context.Context) (T, error) {
+	if err := ctx.Err(); err != nil {
+		return *new(T), err
+	}
+	select {
+	case v := <-q.items:
+		return v, nil
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+}
+
+// TryEnqueue 非阻塞入队，队列满时返回 false
+func (q *BlockingQueue[T]) TryEnqueue(v T) bool {
+	select {
+	case q.items <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// TryDequeue 非阻塞出队，队列空时返回零值和 false
+func (q *BlockingQueue[T]) TryDequeue() (T, bool) {
+	select {
+	case v := <-q.items:
+		return v, true
+	default:
+		return *new(T), false
+	}
+}
+
+// Len 返回队列长度
+func (q *BlockingQueue[T]) Len() int {
+	return len(q.items)
+}
+
+// Cap 返回队列容量
+func (q *BlockingQueue[T]) Cap() int {
+	return cap(q.items)
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockingQueue_Try(t *testing.T) {
+	q := NewBlockingQueue[int](2)
+	require.True(t, q.TryEnqueue(0))
+	require.True(t, q.TryEnqueue(1))
+	// 满了
+	require.False(t, q.TryEnqueue(2))
+	assert.Equal(t, 2, q.Len())
+
+	// 零值也能和空队列区分开
+	v, ok := q.TryDequeue()
+	require.True(t, ok)
+	assert.Equal(t, 0, v)
+	v, ok = q.TryDequeue()
+	require.True(t, ok)
+	assert.Equal(t, 1, v)
+	_, ok = q.TryDequeue()
+	require.False(t, ok)
+}
+
+func TestBlockingQueue_Timeout(t *testing.T) {
+	q := NewBlockingQueue[int](1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, q.Enqueue(context.Background(), 1))
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err = q.Enqueue(ctx, 2)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestBlockingQueue_Concurrent(t *testing.T) {
+	q := NewBlockingQueue[int](4)
+	const n = 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			assert.NoError(t, q.Enqueue(context.Background(), i))
+		}
+	}()
+	sum := 0
+	for i := 0; i < n; i++ {
+		v, err := q.Dequeue(context.Background())
+		require.NoError(t, err)
+		sum += v
+	}
+	wg.Wait()
+	assert.Equal(t, n*(n-1)/2, sum)
+	assert.Equal(t, 0, q.Len())
+}
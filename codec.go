@@ -0,0 +1,23 @@
+package pkg
+
+import "encoding/json"
+
+// Codec 元素的编解码方式
+// 需要把元素写到磁盘或者 redis 之类的外部存储时使用
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec 基于 encoding/json 的编解码
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
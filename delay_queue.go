@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DelayQueue 延迟队列
+// 元素只有在到期之后才能被取出，最早到期的元素最先出队
+type DelayQueue[T any] struct {
+	mu   sync.Mutex
+	heap *binaryHeap[delayItem[T]]
+	// wake 每次有新元素入队就关闭并替换，用于唤醒所有等待中的 Take
+	wake chan struct{}
+}
+
+type delayItem[T any] struct {
+	val      T
+	deadline time.Time
+}
+
+// NewDelayQueue 创建延迟队列
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	return &DelayQueue[T]{
+		heap: newBinaryHeap(func(a, b delayItem[T]) bool {
+			return a.deadline.Before(b.deadline)
+		}),
+		wake: make(chan struct{}),
+	}
+}
+
+// Put 放入一个在 deadline 到期的元素
+func (q *DelayQueue[T]) Put(v T, deadline time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.heap.push(delayItem[T]{val: v, deadline: deadline})
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// PutDelay 放入一个在 delay 之后到期的元素
+func (q *DelayQueue[T]) PutDelay(v T, delay time.Duration) {
+	q.Put(v, time.Now().Add(delay))
+}
+
+// Poll 非阻塞地取出一个已经到期的元素，没有到期元素时返回 false
+func (q *DelayQueue[T]) Poll() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.len() == 0 || q.heap.peek().deadline.After(time.Now()) {
+		return *new(T), false
+	}
+	return q.heap.pop().val, true
+}
+
+// Take 阻塞直到最早的元素到期并将其取出，或者 ctx 结束
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		q.mu.Lock()
+		wake := q.wake
+		var timerCh <-chan time.Time
+		if q.heap.len() > 0 {
+			delay := time.Until(q.heap.peek().deadline)
+			if delay <= 0 {
+				item := q.heap.pop()
+				q.mu.Unlock()
+				return item.val, nil
+			}
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			timerCh = timer.C
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return *new(T), ctx.Err()
+		case <-wake:
+			// 有新元素进来了，可能比当前堆顶更早到期，重新检查
+		case <-timerCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Len 返回队列长度，包含还没有到期的元素
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.heap.len()
+}
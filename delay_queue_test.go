@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelayQueue_Take(t *testing.T) {
+	q := NewDelayQueue[string]()
+	now := time.Now()
+	q.Put("c", now.Add(time.Millisecond*150))
+	q.Put("a", now.Add(time.Millisecond*50))
+	q.Put("b", now.Add(time.Millisecond*100))
+
+	// 还没到期
+	_, ok := q.Poll()
+	require.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []string{"a", "b", "c"} {
+		v, err := q.Take(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, v)
+	}
+	assert.GreaterOrEqual(t, time.Since(now), time.Millisecond*150)
+}
+
+func TestDelayQueue_EarlierItemWakesTaker(t *testing.T) {
+	q := NewDelayQueue[int]()
+	q.PutDelay(1, time.Hour)
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		q.PutDelay(2, time.Millisecond*10)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	v, err := q.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestDelayQueue_Cancel(t *testing.T) {
+	q := NewDelayQueue[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*30)
+	defer cancel()
+	_, err := q.Take(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,514 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrQueueClosed = errors.New("queue closed")
+	// ErrItemSettled 元素已经 Ack 或者 Nack 过了
+	ErrItemSettled = errors.New("item already acked or nacked")
+
+	errCorruptRecord = errors.New("corrupt record")
+)
+
+const (
+	recordPut byte = 1
+	recordAck byte = 2
+
+	// 类型(1) + 序列号(8) + 长度(4) + crc(4)
+	recordHeaderSize   = 17
+	maxRecordSize      = 1 << 30
+	defaultSegmentSize = 64 << 20
+	segmentExt         = ".seg"
+)
+
+// DurableQueue 基于本地磁盘的持久化队列
+// 入队和 ack 都会追加写到分段的日志文件里，重启的时候回放日志恢复队列，
+// 最老的分段里所有元素都 ack 之后就会被删除。
+// 取出来但是没有 Ack 的元素在重启之后会被重新投递，也就是 at-least-once
+type DurableQueue[T any] struct {
+	dir         string
+	codec       Codec[T]
+	segmentSize int64
+	syncWrite   bool
+
+	mu sync.Mutex
+	// 还没有被取出的元素
+	pending []durableEntry[T]
+	// 已经取出但是还没有 ack 的元素
+	inflight map[uint64]durableEntry[T]
+	// 按照创建顺序排列，最后一个就是正在写的分段
+	segments   []*segment
+	active     segmentFile
+	activeSize int64
+	nextSeq    uint64
+	// 有新元素或者队列关闭的时候关闭并替换，用于唤醒所有等待中的 Dequeue
+	wake   chan struct{}
+	closed bool
+	// 切换分段的时候旧分段已经关闭、新分段没有创建成功，之后的写入都直接返回这个错误
+	broken error
+}
+
+// segmentFile 正在写的分段文件，*os.File 实现了这个接口
+type segmentFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+type segment struct {
+	id   uint64
+	path string
+	// 还没有 ack 的入队记录数
+	unacked int
+}
+
+type durableEntry[T any] struct {
+	seq uint64
+	val T
+	seg *segment
+}
+
+// DurableItem 从 DurableQueue 里取出的元素
+// 处理完之后必须调用 Ack，否则重启之后会再次投递
+type DurableItem[T any] struct {
+	Value T
+	seq   uint64
+	q     *DurableQueue[T]
+}
+
+// Ack 确认元素已经处理完
+func (i *DurableItem[T]) Ack() error {
+	return i.q.ack(i.seq)
+}
+
+// Nack 放弃处理，元素会被放回队首重新投递
+func (i *DurableItem[T]) Nack() error {
+	return i.q.nack(i.seq)
+}
+
+// OpenDurableQueue 打开 dir 下的持久化队列，目录不存在会自动创建
+// 打开的时候会回放已有的日志
+func OpenDurableQueue[T any](dir string, codec Codec[T]) (*DurableQueue[T], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create queue dir: %w", err)
+	}
+	q := &DurableQueue[T]{
+		dir:         dir,
+		codec:       codec,
+		segmentSize: defaultSegmentSize,
+		inflight:    make(map[uint64]durableEntry[T]),
+		wake:        make(chan struct{}),
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// SegmentSize 设置单个分段文件的大小上限，超过之后切换到新的分段
+func (q *DurableQueue[T]) SegmentSize(size int64) *DurableQueue[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if size > 0 {
+		q.segmentSize = size
+	}
+	return q
+}
+
+// SyncWrite 设置是否每次写入都 fsync
+// 开启之后机器掉电也不会丢数据，但是写入会慢很多
+func (q *DurableQueue[T]) SyncWrite(sync bool) *DurableQueue[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.syncWrite = sync
+	return q
+}
+
+// Enqueue 入队，写入日志成功之后才返回
+func (q *DurableQueue[T]) Enqueue(v T) error {
+	data, err := q.codec.Encode(v)
+	if err != nil {
+		return fmt.Errorf("encode item: %w", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	seq := q.nextSeq
+	if err = q.writeRecord(recordPut, seq, data); err != nil {
+		return err
+	}
+	q.nextSeq++
+	seg := q.segments[len(q.segments)-1]
+	seg.unacked++
+	q.pending = append(q.pending, durableEntry[T]{seq: seq, val: v, seg: seg})
+	q.broadcast()
+	return nil
+}
+
+// Dequeue 取出队首元素，队列为空时阻塞直到有元素、ctx 结束或者队列关闭
+func (q *DurableQueue[T]) Dequeue(ctx context.Context) (*DurableItem[T], error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+		if len(q.pending) > 0 {
+			item := q.take()
+			q.mu.Unlock()
+			return item, nil
+		}
+		wake := q.wake
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// TryDequeue 非阻塞地取出队首元素，队列为空时返回 false
+func (q *DurableQueue[T]) TryDequeue() (*DurableItem[T], bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || len(q.pending) == 0 {
+		return nil, false
+	}
+	return q.take(), true
+}
+
+// Len 返回等待取出的元素个数，不包含已经取出但是没有 ack 的元素
+func (q *DurableQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// InFlight 返回已经取出但是还没有 ack 的元素个数
+func (q *DurableQueue[T]) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.inflight)
+}
+
+// Close 关闭队列，没有 ack 的元素会在下次打开时重新投递
+func (q *DurableQueue[T]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.broadcast()
+	if q.broken != nil {
+		// 正在写的分段已经关闭了
+		return nil
+	}
+	if err := q.active.Sync(); err != nil {
+		_ = q.active.Close()
+		return err
+	}
+	return q.active.Close()
+}
+
+func (q *DurableQueue[T]) take() *DurableItem[T] {
+	entry := q.pending[0]
+	q.pending[0] = durableEntry[T]{}
+	q.pending = q.pending[1:]
+	q.inflight[entry.seq] = entry
+	return &DurableItem[T]{Value: entry.val, seq: entry.seq, q: q}
+}
+
+func (q *DurableQueue[T]) ack(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	entry, ok := q.inflight[seq]
+	if !ok {
+		return ErrItemSettled
+	}
+	if err := q.writeRecord(recordAck, seq, nil); err != nil {
+		return err
+	}
+	delete(q.inflight, seq)
+	entry.seg.unacked--
+	return q.compact()
+}
+
+func (q *DurableQueue[T]) nack(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	entry, ok := q.inflight[seq]
+	if !ok {
+		return ErrItemSettled
+	}
+	delete(q.inflight, seq)
+	// 放回队首，不需要写日志，重启之后本来就会重新投递
+	q.pending = append([]durableEntry[T]{entry}, q.pending...)
+	q.broadcast()
+	return nil
+}
+
+func (q *DurableQueue[T]) broadcast() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// compact 从最老的分段开始删除已经全部 ack 的分段
+// 只能从头开始删，因为后面分段里的 ack 记录可能对应前面分段里的元素
+func (q *DurableQueue[T]) compact() error {
+	for len(q.segments) > 1 && q.segments[0].unacked == 0 {
+		if err := os.Remove(q.segments[0].path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove segment: %w", err)
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+func (q *DurableQueue[T]) writeRecord(typ byte, seq uint64, payload []byte) error {
+	if q.broken != nil {
+		return fmt.Errorf("queue broken: %w", q.broken)
+	}
+	if q.activeSize >= q.segmentSize {
+		if err := q.roll(); err != nil {
+			return err
+		}
+	}
+	buf := make([]byte, recordHeaderSize+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint64(buf[1:9], seq)
+	binary.BigEndian.PutUint32(buf[9:13], uint32(len(payload)))
+	copy(buf[recordHeaderSize:], payload)
+	binary.BigEndian.PutUint32(buf[13:17], recordChecksum(buf))
+	n, err := q.active.Write(buf)
+	if err != nil {
+		err = fmt.Errorf("write record: %w", err)
+		if n > 0 {
+			if derr := q.discardTorn(); derr != nil {
+				return errors.Join(err, derr)
+			}
+		}
+		return err
+	}
+	q.activeSize += int64(n)
+	if q.syncWrite {
+		if err = q.active.Sync(); err != nil {
+			return fmt.Errorf("sync segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// discardTorn 写了一半的记录后面再追加的话，回放的时候后面的记录都读不出来
+// 先尝试截断回写之前的位置，截断不了就切换到新的分段，残缺的记录留在旧分段的末尾，回放的时候会被丢掉
+// 切换分段也失败的话返回错误，之后的写入都会失败，见 roll
+func (q *DurableQueue[T]) discardTorn() error {
+	if err := q.active.Truncate(q.activeSize); err == nil {
+		if _, err = q.active.Seek(q.activeSize, io.SeekStart); err == nil {
+			return nil
+		}
+	}
+	if err := q.roll(); err != nil {
+		return fmt.Errorf("discard torn record: %w", err)
+	}
+	return nil
+}
+
+// roll 关闭当前分段，切换到一个新的分段
+func (q *DurableQueue[T]) roll() error {
+	var id uint64
+	if len(q.segments) > 0 {
+		id = q.segments[len(q.segments)-1].id + 1
+		if err := q.active.Close(); err != nil {
+			return fmt.Errorf("close segment: %w", err)
+		}
+	}
+	seg := &segment{
+		id:   id,
+		path: filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExt)),
+	}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		err = fmt.Errorf("create segment: %w", err)
+		if len(q.segments) > 0 {
+			// 旧的分段已经关闭了，q.active 不能再用
+			q.broken = err
+		}
+		return err
+	}
+	q.segments = append(q.segments, seg)
+	q.active = f
+	q.activeSize = 0
+	// 之前的分段可能在切换前就已经全部 ack 了
+	return q.compact()
+}
+
+// replay 按顺序回放所有分段，恢复没有 ack 的元素
+func (q *DurableQueue[T]) replay() error {
+	ids, err := q.listSegments()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return q.roll()
+	}
+	var puts []durableEntry[T]
+	acked := make(map[uint64]struct{})
+	var lastSize int64
+	for i, id := range ids {
+		seg := &segment{
+			id:   id,
+			path: filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExt)),
+		}
+		last := i == len(ids)-1
+		size, err := q.readSegment(seg, last, func(typ byte, seq uint64, payload []byte) error {
+			switch typ {
+			case recordPut:
+				v, err := q.codec.Decode(payload)
+				if err != nil {
+					return fmt.Errorf("decode item %d: %w", seq, err)
+				}
+				puts = append(puts, durableEntry[T]{seq: seq, val: v, seg: seg})
+			case recordAck:
+				acked[seq] = struct{}{}
+			}
+			// ack 对应的入队记录可能已经随着分段被删掉了，序列号也要跳过，
+			// 否则新的元素会复用旧的序列号，被残留的 ack 记录当成已经处理完了
+			if seq >= q.nextSeq {
+				q.nextSeq = seq + 1
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, seg)
+		lastSize = size
+	}
+	for _, p := range puts {
+		if _, ok := acked[p.seq]; ok {
+			continue
+		}
+		p.seg.unacked++
+		q.pending = append(q.pending, p)
+	}
+	// 继续往最后一个分段里追加
+	last := q.segments[len(q.segments)-1]
+	f, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	q.active = f
+	q.activeSize = lastSize
+	return q.compact()
+}
+
+// readSegment 读取分段里的所有记录，返回有效数据的长度
+// 分段末尾如果有写了一半的记录（比如进程崩溃，或者写失败之后切换了分段），会被截断掉，
+// 最后一个分段末尾校验失败的记录也会被截断
+func (q *DurableQueue[T]) readSegment(seg *segment, last bool,
+	fn func(typ byte, seq uint64, payload []byte) error) (int64, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return 0, fmt.Errorf("open segment: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		_, err = io.ReadFull(r, header)
+		if err == io.EOF {
+			return offset, nil
+		}
+		var payload []byte
+		if err == nil {
+			size := binary.BigEndian.Uint32(header[9:13])
+			if size > maxRecordSize {
+				err = errCorruptRecord
+			} else {
+				payload = make([]byte, size)
+				_, err = io.ReadFull(r, payload)
+				// 刚好写完了记录头，后面一个字节都没有
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+			}
+		}
+		if err == nil {
+			buf := append(header[:recordHeaderSize:recordHeaderSize], payload...)
+			if recordChecksum(buf) != binary.BigEndian.Uint32(header[13:17]) {
+				err = errCorruptRecord
+			}
+		}
+		if err != nil {
+			torn := err == io.ErrUnexpectedEOF || (last && err == errCorruptRecord)
+			if !torn {
+				return 0, fmt.Errorf("read segment %s: %w", seg.path, err)
+			}
+			// 写了一半的记录，截断
+			if err = os.Truncate(seg.path, offset); err != nil {
+				return 0, fmt.Errorf("truncate segment: %w", err)
+			}
+			return offset, nil
+		}
+		if err = fn(header[0], binary.BigEndian.Uint64(header[1:9]), payload); err != nil {
+			return 0, err
+		}
+		offset += int64(recordHeaderSize + len(payload))
+	}
+}
+
+func (q *DurableQueue[T]) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read queue dir: %w", err)
+	}
+	ids := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+// recordChecksum 计算除了 crc 字段之外的所有内容的 crc
+func recordChecksum(record []byte) uint32 {
+	crc := crc32.ChecksumIEEE(record[:13])
+	return crc32.Update(crc, crc32.IEEETable, record[recordHeaderSize:])
+}
@@ -0,0 +1,273 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type durableEvent struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDurableQueue_Basic(t *testing.T) {
+	q, err := OpenDurableQueue[durableEvent](t.TempDir(), JSONCodec[durableEvent]{})
+	require.NoError(t, err)
+	defer q.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		require.NoError(t, q.Enqueue(durableEvent{ID: i, Name: "evt"}))
+	}
+	assert.Equal(t, 3, q.Len())
+
+	item, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), item.Value.ID)
+	assert.Equal(t, 1, q.InFlight())
+	require.NoError(t, item.Ack())
+	assert.ErrorIs(t, item.Ack(), ErrItemSettled)
+
+	// Nack 之后回到队首
+	item, ok := q.TryDequeue()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), item.Value.ID)
+	require.NoError(t, item.Nack())
+	item, ok = q.TryDequeue()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), item.Value.ID)
+}
+
+func TestDurableQueue_Blocking(t *testing.T) {
+	q, err := OpenDurableQueue[int](t.TempDir(), JSONCodec[int]{})
+	require.NoError(t, err)
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*30)
+	defer cancel()
+	_, err = q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = q.Enqueue(7)
+	}()
+	item, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, item.Value)
+}
+
+func TestDurableQueue_Replay(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, q.Enqueue(i))
+	}
+	// 0 ack 了，1 取出来但是没有 ack，2 3 4 没有取
+	item, _ := q.TryDequeue()
+	require.NoError(t, item.Ack())
+	_, _ = q.TryDequeue()
+	require.NoError(t, q.Close())
+	_, err = q.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrQueueClosed)
+
+	q, err = OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 4, q.Len())
+	var got []int
+	for {
+		item, ok := q.TryDequeue()
+		if !ok {
+			break
+		}
+		got = append(got, item.Value)
+		require.NoError(t, item.Ack())
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, got)
+
+	// 序列号接着之前的继续
+	require.NoError(t, q.Enqueue(5))
+	item, _ = q.TryDequeue()
+	assert.Equal(t, 5, item.Value)
+}
+
+func TestDurableQueue_Compact(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenDurableQueue[string](dir, JSONCodec[string]{})
+	require.NoError(t, err)
+	// 每条记录大概 20 多个字节，让它频繁切换分段
+	q.SegmentSize(64)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, q.Enqueue("message"))
+	}
+	assert.Greater(t, countSegments(t, dir), 5)
+
+	for i := 0; i < 20; i++ {
+		item, ok := q.TryDequeue()
+		require.True(t, ok)
+		require.NoError(t, item.Ack())
+	}
+	// 只剩下正在写的分段
+	assert.Equal(t, 1, countSegments(t, dir))
+	require.NoError(t, q.Close())
+
+	q, err = OpenDurableQueue[string](dir, JSONCodec[string]{})
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestDurableQueue_TornWrite(t *testing.T) {
+	// 只写完了记录头，payload 一个字节都没有写
+	header := make([]byte, recordHeaderSize)
+	header[0] = recordPut
+	binary.BigEndian.PutUint64(header[1:9], 2)
+	binary.BigEndian.PutUint32(header[9:13], 8)
+	testCases := []struct {
+		name string
+		torn []byte
+	}{
+		{name: "记录头写了一半", torn: []byte{recordPut, 0, 0, 0}},
+		{name: "只写完了记录头", torn: header},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q, err := OpenDurableQueue[int](dir, JSONCodec[int]{})
+			require.NoError(t, err)
+			require.NoError(t, q.Enqueue(1))
+			require.NoError(t, q.Enqueue(2))
+			require.NoError(t, q.Close())
+
+			// 模拟写到一半进程崩溃
+			path := filepath.Join(dir, "00000000000000000000.seg")
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+			require.NoError(t, err)
+			_, err = f.Write(tc.torn)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			q, err = OpenDurableQueue[int](dir, JSONCodec[int]{})
+			require.NoError(t, err)
+			defer q.Close()
+			assert.Equal(t, 2, q.Len())
+			require.NoError(t, q.Enqueue(3))
+			assert.Equal(t, 3, q.Len())
+		})
+	}
+}
+
+func TestDurableQueue_RestartAfterCompact(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	q.SegmentSize(60)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, q.Enqueue(i))
+	}
+	for i := 0; i < 4; i++ {
+		item, ok := q.TryDequeue()
+		require.True(t, ok)
+		require.NoError(t, item.Ack())
+	}
+	require.NoError(t, q.Close())
+
+	// 入队记录所在的分段都被删掉了，只剩下 ack 记录，新的元素不能复用旧的序列号
+	q, err = OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(100))
+	require.NoError(t, q.Close())
+
+	q, err = OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	defer q.Close()
+	require.Equal(t, 1, q.Len())
+	item, ok := q.TryDequeue()
+	require.True(t, ok)
+	assert.Equal(t, 100, item.Value)
+}
+
+// shortWriter 第一次写只写一半，然后返回错误
+type shortWriter struct {
+	segmentFile
+	failed       bool
+	failTruncate bool
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		return w.segmentFile.Write(p)
+	}
+	w.failed = true
+	n, _ := w.segmentFile.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func (w *shortWriter) Truncate(size int64) error {
+	if w.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return w.segmentFile.Truncate(size)
+}
+
+func TestDurableQueue_FailedWrite(t *testing.T) {
+	testCases := []struct {
+		name         string
+		failTruncate bool
+	}{
+		{name: "截断残缺的记录"},
+		{name: "截断失败切换分段", failTruncate: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q, err := OpenDurableQueue[int](dir, JSONCodec[int]{})
+			require.NoError(t, err)
+			require.NoError(t, q.Enqueue(1))
+			q.active = &shortWriter{segmentFile: q.active, failTruncate: tc.failTruncate}
+			assert.Error(t, q.Enqueue(2))
+			require.NoError(t, q.Enqueue(3))
+			require.NoError(t, q.Close())
+
+			q, err = OpenDurableQueue[int](dir, JSONCodec[int]{})
+			require.NoError(t, err)
+			defer q.Close()
+			require.Equal(t, 2, q.Len())
+			for _, want := range []int{1, 3} {
+				item, ok := q.TryDequeue()
+				require.True(t, ok)
+				assert.Equal(t, want, item.Value)
+			}
+		})
+	}
+}
+
+func TestDurableQueue_FailedRoll(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenDurableQueue[int](dir, JSONCodec[int]{})
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(1))
+	q.active = &shortWriter{segmentFile: q.active, failTruncate: true}
+	// 截断失败，新的分段也创建不了
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, q.Enqueue(2))
+	// 旧的分段已经关闭了，之后的写入都直接失败
+	assert.ErrorContains(t, q.Enqueue(3), "queue broken")
+	assert.Equal(t, 1, q.Len())
+	assert.NoError(t, q.Close())
+}
+
+func countSegments(t *testing.T, dir string) int {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	return len(matches)
+}
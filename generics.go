@@ -19,16 +19,18 @@ func SliceToPtrSlice[T any](src []T) []*T {
 }
 
 // SliceDiffSet 计算两个切片的差集 只支持 comparable 类型
+// 结果按 src 中第一次出现的顺序返回，并且去重
 func SliceDiffSet[T comparable](src []T, dst []T) []T {
-	srcMap := ToMap(src)
-	for _, val := range dst {
-		delete(srcMap, val)
-	}
-	var ret = make([]T, 0, len(srcMap))
-	for key := range srcMap {
-		ret = append(ret, key)
+	exclude := NewSet(dst...)
+	var ret = make([]T, 0, len(src))
+	for _, val := range src {
+		if exclude.Contains(val) {
+			continue
+		}
+		// 加进去，后面重复的元素也会被跳过
+		exclude.Add(val)
+		ret = append(ret, val)
 	}
-
 	return ret
 }
 
@@ -0,0 +1,13 @@
+package ginx
+
+import (
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// LevelHandler 查看和修改日志级别的接口，用法见 logger.LevelHandler
+//
+//	server.Any("/debug/log/level", ginx.LevelHandler(logger.DefaultLevels))
+func LevelHandler(levels *logger.LevelRegistry) gin.HandlerFunc {
+	return gin.WrapH(logger.NewLevelHandler(levels))
+}
@@ -0,0 +1,66 @@
+package adaptive
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/aegis/ratelimit"
+)
+
+// Builder 自适应限流中间件，系统过载的时候返回 503
+type Builder struct {
+	limiter ratelimit.Limiter
+	// 路由前缀，例如健康检查和指标接口
+	exempts []string
+}
+
+// NewBuilder limiter 一般是 ratelimit/adaptive 的 Limiter，也可以是 aegis 的 bbr
+func NewBuilder(limiter ratelimit.Limiter) *Builder {
+	return &Builder{
+		limiter: limiter,
+	}
+}
+
+// Exempt 路由（gin 的 FullPath，没有匹配到路由的时候是原始路径）以这些前缀开头的请求不受限制，也不参与统计
+func (b *Builder) Exempt(prefixes ...string) *Builder {
+	b.exempts = append(b.exempts, prefixes...)
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if b.exempt(ctx) {
+			ctx.Next()
+			return
+		}
+		done, err := b.limiter.Allow()
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		// 后面的 handler panic 了也要调用 done，不然并发数会一直算着这个请求
+		defer func() {
+			// Errors.Last 返回的是 *gin.Error，没有错误的时候直接赋值给 error 会变成非 nil 的接口
+			var err error
+			if last := ctx.Errors.Last(); last != nil {
+				err = last
+			}
+			done(ratelimit.DoneInfo{Err: err})
+		}()
+		ctx.Next()
+	}
+}
+
+func (b *Builder) exempt(ctx *gin.Context) bool {
+	route := ctx.FullPath()
+	if route == "" {
+		route = ctx.Request.URL.Path
+	}
+	for _, prefix := range b.exempts {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package adaptive
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/aegis/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// switchLimiter 按照 drop 决定是否拒绝，记录有多少个请求还没有调用 done
+type switchLimiter struct {
+	drop     bool
+	inFlight int
+	// 最后一次 done 收到的 DoneInfo
+	info ratelimit.DoneInfo
+}
+
+func (s *switchLimiter) Allow() (ratelimit.DoneFunc, error) {
+	if s.drop {
+		return nil, ratelimit.ErrLimitExceed
+	}
+	s.inFlight++
+	return func(info ratelimit.DoneInfo) {
+		s.inFlight--
+		s.info = info
+	}, nil
+}
+
+func TestBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := &switchLimiter{}
+	server := gin.New()
+	server.Use(NewBuilder(limiter).Exempt("/health").Build())
+	server.GET("/hello", func(ctx *gin.Context) {
+		assert.Equal(t, 1, limiter.inFlight)
+		ctx.String(http.StatusOK, "hello")
+	})
+	server.GET("/health", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 0, limiter.inFlight)
+	assert.NoError(t, limiter.info.Err)
+
+	limiter.drop = true
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestBuilder_DoneErr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := &switchLimiter{}
+	server := gin.New()
+	server.Use(NewBuilder(limiter).Build())
+	server.GET("/hello", func(ctx *gin.Context) {
+		_ = ctx.Error(errors.New("db error"))
+		ctx.String(http.StatusInternalServerError, "error")
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.EqualError(t, limiter.info.Err, "db error")
+}
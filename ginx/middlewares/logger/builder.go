@@ -6,6 +6,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/Kirby980/go-pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,6 +15,8 @@ type MiddlewareBuilder struct {
 	allowRespBody bool
 	bodySize      int
 	loggerFunc    func(ctx context.Context, al *AccessLog)
+	// 不为空的时候，URL、请求体、响应体脱敏之后再交给 loggerFunc
+	redactor *logger.Redactor
 }
 
 func NewBuilder(fn func(ctx context.Context, al *AccessLog)) *MiddlewareBuilder {
@@ -38,10 +41,20 @@ func (m *MiddlewareBuilder) AllowRespBody() *MiddlewareBuilder {
 	return m
 
 }
+
+// Redact 对 URL 的查询参数、请求体和响应体脱敏
+func (m *MiddlewareBuilder) Redact(r *logger.Redactor) *MiddlewareBuilder {
+	m.redactor = r
+	return m
+}
+
 func (m *MiddlewareBuilder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		start := time.Now()
 		url := ctx.Request.URL.String()
+		if m.redactor != nil {
+			url = m.redactor.URL(url)
+		}
 		if len(url) > m.bodySize {
 			url = url[:m.bodySize]
 		}
@@ -53,6 +66,10 @@ func (m *MiddlewareBuilder) Build() gin.HandlerFunc {
 			//body 读完就没了是iostream
 			body, _ := ctx.GetRawData()
 			ctx.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+			// 先脱敏再截断，避免截断之后识别不出来
+			if m.redactor != nil {
+				body = []byte(m.redactor.JSON(string(body)))
+			}
 			if len(body) > m.bodySize {
 				body = body[:m.bodySize]
 			}
@@ -67,6 +84,9 @@ func (m *MiddlewareBuilder) Build() gin.HandlerFunc {
 		}
 		defer func() {
 			al.Duration = time.Since(start).String()
+			if m.redactor != nil && al.RespBody != "" {
+				al.RespBody = m.redactor.JSON(al.RespBody)
+			}
 			m.loggerFunc(ctx, al)
 		}()
 		ctx.Next()
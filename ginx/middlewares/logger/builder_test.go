@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder_Redact(t *testing.T) {
+	redactor := logger.NewRedactor().Keys(logger.MaskFull, "password", "token")
+	testCases := []struct {
+		name    string
+		builder func(fn func(ctx context.Context, al *AccessLog)) *MiddlewareBuilder
+		wantLog AccessLog
+	}{
+		{
+			name: "不脱敏",
+			builder: func(fn func(ctx context.Context, al *AccessLog)) *MiddlewareBuilder {
+				return NewBuilder(fn).SetBodySize(1024).AllowReqBody().AllowRespBody()
+			},
+			wantLog: AccessLog{
+				Method:   http.MethodPost,
+				Url:      "/login?password=123",
+				ReqBody:  `{"password":"123"}`,
+				RespBody: `{"token":"abc"}`,
+			},
+		},
+		{
+			name: "脱敏",
+			builder: func(fn func(ctx context.Context, al *AccessLog)) *MiddlewareBuilder {
+				return NewBuilder(fn).SetBodySize(1024).AllowReqBody().AllowRespBody().Redact(redactor)
+			},
+			wantLog: AccessLog{
+				Method:   http.MethodPost,
+				Url:      "/login?password=******",
+				ReqBody:  `{"password":"******"}`,
+				RespBody: `{"token":"******"}`,
+			},
+		},
+		{
+			name: "先脱敏再截断",
+			builder: func(fn func(ctx context.Context, al *AccessLog)) *MiddlewareBuilder {
+				return NewBuilder(fn).SetBodySize(16).AllowReqBody().Redact(redactor)
+			},
+			wantLog: AccessLog{
+				Method:  http.MethodPost,
+				Url:     "/login?password=",
+				ReqBody: `{"password":"***`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var al *AccessLog
+			server := gin.New()
+			server.Use(tc.builder(func(ctx context.Context, log *AccessLog) {
+				al = log
+			}).Build())
+			server.POST("/login", func(ctx *gin.Context) {
+				// 业务代码还能读到原始的请求体
+				body, err := ctx.GetRawData()
+				require.NoError(t, err)
+				assert.Equal(t, `{"password":"123"}`, string(body))
+				ctx.String(http.StatusOK, `{"token":"abc"}`)
+			})
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost,
+				"/login?password=123", strings.NewReader(`{"password":"123"}`)))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			require.NotNil(t, al)
+			al.Duration = ""
+			assert.Equal(t, tc.wantLog, *al)
+		})
+	}
+}
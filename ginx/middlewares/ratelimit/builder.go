@@ -5,15 +5,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Kirby980/go-pkg/ratelimit"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type Builder struct {
 	prefix string
 	// 阈值
 	limiter ratelimit.Limiter
+	// 设置了之后按照多条规则限流，不再使用 limiter
+	rules  ratelimit.RuleLimiter
+	userID func(ctx *gin.Context) string
 }
 
 func NewBuilder(limiter ratelimit.Limiter) *Builder {
@@ -28,9 +34,39 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+// Rules 按照多条规则限流，例如全局、路由、用户、IP、请求头，
+// 设置了之后 limiter 和 prefix 都不再使用，key 由 RuleLimiter 决定
+func (b *Builder) Rules(l ratelimit.RuleLimiter) *Builder {
+	b.rules = l
+	return b
+}
+
+// Claims 从 ctx.Get(key) 里面拿到 jwt.Claims，用 subject 作为用户 ID，
+// 需要放在登录校验的中间件后面
+func (b *Builder) Claims(key string) *Builder {
+	return b.UserID(func(ctx *gin.Context) string {
+		val, ok := ctx.Get(key)
+		if !ok {
+			return ""
+		}
+		c, ok := val.(jwt.Claims)
+		if !ok {
+			return ""
+		}
+		sub, _ := c.GetSubject()
+		return sub
+	})
+}
+
+// UserID 自定义怎么拿到用户 ID，返回空字符串代表没有登录
+func (b *Builder) UserID(fn func(ctx *gin.Context) string) *Builder {
+	b.userID = fn
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limited, err := b.limit(ctx)
+		d, err := b.limit(ctx)
 		if err != nil {
 			log.Println(err)
 			// 这一步很有意思，就是如果这边出错了
@@ -38,8 +74,8 @@ func (b *Builder) Build() gin.HandlerFunc {
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		if limited {
-			log.Println(err)
+		setHeaders(ctx, d)
+		if !d.Allowed {
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
@@ -47,7 +83,53 @@ func (b *Builder) Build() gin.HandlerFunc {
 	}
 }
 
-func (b *Builder) limit(ctx *gin.Context) (bool, error) {
+func (b *Builder) limit(ctx *gin.Context) (ratelimit.Decision, error) {
+	if b.rules != nil {
+		return b.limitRules(ctx)
+	}
 	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.limiter.Limit(ctx, key)
+	return ratelimit.Decide(ctx, b.limiter, key)
+}
+
+func (b *Builder) limitRules(ctx *gin.Context) (ratelimit.Decision, error) {
+	req := ratelimit.Request{
+		Method: ctx.Request.Method,
+		Route:  ctx.FullPath(),
+		IP:     ctx.ClientIP(),
+		Header: ctx.GetHeader,
+	}
+	// 没有匹配到路由的时候用原始路径
+	if req.Route == "" {
+		req.Route = ctx.Request.URL.Path
+	}
+	if b.userID != nil {
+		req.UserID = b.userID(ctx)
+	}
+	d, err := b.rules.DecideRequest(ctx, req)
+	if err == nil && d.Rule != "" && !d.Allowed {
+		ctx.Header("X-RateLimit-Rule", d.Rule)
+	}
+	return d.Decision, err
+}
+
+// setHeaders 限流器返回了详细结果的时候，告诉客户端剩余的额度和什么时候可以重试
+// X-RateLimit-Reset 是额度完全恢复的 Unix 时间戳，单位秒
+func setHeaders(ctx *gin.Context, d ratelimit.Decision) {
+	if d.Limit > 0 {
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(ceilUnix(d.ResetAt), 10))
+	}
+	if !d.Allowed && d.RetryAfter > 0 {
+		// Retry-After 只能是整数秒，向上取整
+		secs := (d.RetryAfter + time.Second - 1) / time.Second
+		ctx.Header("Retry-After", strconv.FormatInt(int64(secs), 10))
+	}
+}
+
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
 }
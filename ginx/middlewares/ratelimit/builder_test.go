@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+	"github.com/Kirby980/go-pkg/ratelimit/local"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewBuilder(local.NewFixedWindowLimiter(time.Minute, 1)).Build())
+	server.GET("/hello", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hello")
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", recorder.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, recorder.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, recorder.Header().Get("Retry-After"))
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "60", recorder.Header().Get("Retry-After"))
+}
+
+type recordRuleLimiter struct {
+	req ratelimit.Request
+	d   ratelimit.RuleDecision
+}
+
+func (r *recordRuleLimiter) DecideRequest(ctx context.Context, req ratelimit.Request) (ratelimit.RuleDecision, error) {
+	r.req = req
+	return r.d, nil
+}
+
+func TestBuilder_Rules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rules := &recordRuleLimiter{d: ratelimit.RuleDecision{
+		Decision: ratelimit.Decision{Limit: 10, RetryAfter: time.Second},
+		Rule:     "user",
+	}}
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", jwt.RegisteredClaims{Subject: "123"})
+	})
+	server.Use(NewBuilder(nil).Rules(rules).Claims("claims").Build())
+	server.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hello")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-App-Key", "app")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "user", recorder.Header().Get("X-RateLimit-Rule"))
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+	assert.Equal(t, http.MethodGet, rules.req.Method)
+	assert.Equal(t, "/users/:id", rules.req.Route)
+	assert.Equal(t, "123", rules.req.UserID)
+	assert.Equal(t, "192.0.2.1", rules.req.IP)
+	assert.Equal(t, "app", rules.req.Header("X-App-Key"))
+}
+
+func TestSemaphoreBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	sem := ratelimit.NewRedisSemaphore(client, 1)
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	server := gin.New()
+	server.Use(NewSemaphoreBuilder(sem, "semaphore:ai").Build())
+	server.GET("/summary", func(ctx *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	}()
+	<-entered
+
+	// 许可被占用了，直接拒绝
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// 第一个请求结束之后释放了许可
+	go func() {
+		<-entered
+	}()
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestSemaphoreBuilder_KeepAlive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	sem := ratelimit.NewRedisSemaphore(client, 1).Lease(time.Millisecond * 150)
+
+	server := gin.New()
+	server.Use(NewSemaphoreBuilder(sem, "semaphore:ai").Build())
+	server.GET("/summary", func(ctx *gin.Context) {
+		// 处理的时间超过了租约
+		time.Sleep(time.Millisecond * 400)
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	}()
+	// 租约过期之后许可还在第一个请求手里
+	time.Sleep(time.Millisecond * 300)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+}
+
+func TestBuilder_Registry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	registry, err := ratelimit.NewRegistry(ratelimit.NewRedisLimiterFactory(client), ratelimit.KeyRule{
+		Name: "ip", Pattern: "ip-limiter:*", Algorithm: ratelimit.AlgorithmSlidingWindow, Window: time.Minute, Rate: 1,
+	})
+	require.NoError(t, err)
+	server := gin.New()
+	server.Use(NewBuilder(registry).Build())
+	server.GET("/hello", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hello")
+	})
+	serve := func() int {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusTooManyRequests, serve())
+
+	// 不用重新构建中间件，替换规则之后马上生效
+	require.NoError(t, registry.Update(1, []ratelimit.KeyRule{{
+		Name: "ip", Pattern: "ip-limiter:*", Algorithm: ratelimit.AlgorithmSlidingWindow, Window: time.Minute, Rate: 3,
+	}}))
+	assert.Equal(t, http.StatusOK, serve())
+}
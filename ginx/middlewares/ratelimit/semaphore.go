@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// SemaphoreBuilder 限制整个集群同时在处理的请求数，例如调用 AI 服务的接口
+type SemaphoreBuilder struct {
+	sem ratelimit.Semaphore
+	key string
+	// 0 代表没有许可的时候直接拒绝
+	wait time.Duration
+}
+
+func NewSemaphoreBuilder(sem ratelimit.Semaphore, key string) *SemaphoreBuilder {
+	return &SemaphoreBuilder{
+		sem: sem,
+		key: key,
+	}
+}
+
+// Wait 没有许可的时候最多排队等多久，默认不等待，直接返回 429
+func (b *SemaphoreBuilder) Wait(d time.Duration) *SemaphoreBuilder {
+	b.wait = d
+	return b
+}
+
+func (b *SemaphoreBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		permit, err := b.acquire(ctx.Request.Context())
+		switch {
+		case errors.Is(err, ratelimit.ErrNoPermit), errors.Is(err, context.DeadlineExceeded),
+			errors.Is(err, context.Canceled):
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		case err != nil:
+			log.Println(err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		// 处理的时间可能超过租约，处理完之前一直续约
+		stop := ratelimit.KeepAlive(permit, func(err error) {
+			log.Println(err)
+		})
+		defer func() {
+			stop()
+			// 请求可能已经被取消了，释放许可不能用请求的 ctx
+			rctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := permit.Release(rctx); err != nil && !errors.Is(err, ratelimit.ErrPermitLost) {
+				log.Println(err)
+			}
+		}()
+		ctx.Next()
+	}
+}
+
+func (b *SemaphoreBuilder) acquire(ctx context.Context) (ratelimit.Permit, error) {
+	if b.wait <= 0 {
+		return b.sem.TryAcquire(ctx, b.key)
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.wait)
+	defer cancel()
+	return b.sem.Acquire(ctx, b.key)
+}
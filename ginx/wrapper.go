@@ -29,7 +29,7 @@ func WrapBody[Req any](fn func(ctx *gin.Context, req Req) (Result, error)) gin.H
 		}
 		res, err := fn(ctx, req)
 		if err != nil {
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -54,7 +54,7 @@ func WrapBodyAndToken[Req any, C jwt.Claims](fn func(ctx *gin.Context, req Req,
 		}
 		var req Req
 		if err := ctx.Bind(&req); err != nil {
-			L.Error("参数错误",
+			logger.Ctx(ctx.Request.Context(), L).Error("参数错误",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -62,7 +62,7 @@ func WrapBodyAndToken[Req any, C jwt.Claims](fn func(ctx *gin.Context, req Req,
 		}
 		res, err := fn(ctx, req, c)
 		if err != nil {
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -89,7 +89,7 @@ func WrapToken[C jwt.Claims](fn func(ctx *gin.Context, uc C) (Result, error), cl
 
 		res, err := fn(ctx, c)
 		if err != nil {
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -106,7 +106,7 @@ func Wrap(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
 		res, err := fn(ctx)
 		if err != nil {
 			// 开始处理 error，其实就是记录一下日志
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				// 命中的路由
 				logger.String("route", ctx.FullPath()),
@@ -130,7 +130,7 @@ func WrapBodyAndOptionalToken[Req any, C jwt.Claims](fn func(ctx *gin.Context, r
 
 		var req Req
 		if err := ctx.Bind(&req); err != nil {
-			L.Error("参数错误",
+			logger.Ctx(ctx.Request.Context(), L).Error("参数错误",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -138,7 +138,7 @@ func WrapBodyAndOptionalToken[Req any, C jwt.Claims](fn func(ctx *gin.Context, r
 		}
 		res, err := fn(ctx, req, c)
 		if err != nil {
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
@@ -161,7 +161,7 @@ func WrapOptionalToken[C jwt.Claims](fn func(ctx *gin.Context, uc C) (Result, er
 
 		res, err := fn(ctx, c)
 		if err != nil {
-			L.Error("处理业务逻辑出错",
+			logger.Ctx(ctx.Request.Context(), L).Error("处理业务逻辑出错",
 				logger.String("path", ctx.Request.URL.Path),
 				logger.String("route", ctx.FullPath()),
 				logger.Error(err))
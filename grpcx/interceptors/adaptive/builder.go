@@ -0,0 +1,74 @@
+package adaptive
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kratos/aegis/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// InterceptorBuilder 自适应限流拦截器构建器，系统过载的时候返回 ResourceExhausted
+type InterceptorBuilder struct {
+	limiter ratelimit.Limiter
+	// 方法名前缀，例如 /grpc.health.v1.Health/
+	exempts []string
+}
+
+// NewInterceptorBuilder limiter 一般是 ratelimit/adaptive 的 Limiter，也可以是 aegis 的 bbr
+func NewInterceptorBuilder(limiter ratelimit.Limiter) *InterceptorBuilder {
+	return &InterceptorBuilder{
+		limiter: limiter,
+	}
+}
+
+// Exempt 完整方法名以这些前缀开头的请求不受限制，也不参与统计
+func (b *InterceptorBuilder) Exempt(prefixes ...string) *InterceptorBuilder {
+	b.exempts = append(b.exempts, prefixes...)
+	return b
+}
+
+// BuildServerInterceptor 构建服务端拦截器
+func (b *InterceptorBuilder) BuildServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if b.exempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		done, allowErr := b.limiter.Allow()
+		if allowErr != nil {
+			return nil, status.Error(codes.ResourceExhausted, "系统繁忙")
+		}
+		defer func() {
+			done(ratelimit.DoneInfo{Err: err})
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// BuildStreamServerInterceptor 构建服务端流拦截器，整个流算一个请求
+func (b *InterceptorBuilder) BuildStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		if b.exempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		done, allowErr := b.limiter.Allow()
+		if allowErr != nil {
+			return status.Error(codes.ResourceExhausted, "系统繁忙")
+		}
+		defer func() {
+			done(ratelimit.DoneInfo{Err: err})
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func (b *InterceptorBuilder) exempt(fullMethod string) bool {
+	for _, prefix := range b.exempts {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
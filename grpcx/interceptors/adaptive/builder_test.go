@@ -0,0 +1,63 @@
+package adaptive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/aegis/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type switchLimiter struct {
+	drop     bool
+	inFlight int
+}
+
+func (s *switchLimiter) Allow() (ratelimit.DoneFunc, error) {
+	if s.drop {
+		return nil, ratelimit.ErrLimitExceed
+	}
+	s.inFlight++
+	return func(ratelimit.DoneInfo) {
+		s.inFlight--
+	}, nil
+}
+
+func TestInterceptorBuilder(t *testing.T) {
+	limiter := &switchLimiter{}
+	builder := NewInterceptorBuilder(limiter).Exempt("/grpc.health.v1.Health/")
+	unary := builder.BuildServerInterceptor()
+	stream := builder.BuildStreamServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetByID"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		assert.Equal(t, 1, limiter.inFlight)
+		return "ok", nil
+	}
+
+	resp, err := unary(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	assert.Equal(t, 0, limiter.inFlight)
+
+	limiter.drop = true
+	_, err = unary(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	err = stream(nil, nil, &grpc.StreamServerInfo{FullMethod: "/user.v1.UserService/Watch"},
+		func(srv any, ss grpc.ServerStream) error {
+			return nil
+		})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// 健康检查不受限制
+	_, err = unary(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		})
+	require.NoError(t, err)
+}
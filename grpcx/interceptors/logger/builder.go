@@ -20,14 +20,57 @@ type InterceptorBuilder struct {
 	interceptors.Builder
 	reqBody  bool
 	respBody bool
+	// 请求和响应脱敏之后再打印，默认是 defaultRedactor
+	redactor *logger.Redactor
 }
 
+// NewInterceptorBuilder reqBody、respBody 为 true 的时候在日志里打印请求和响应。
+// 注意：以前这两个参数是被忽略的，现在会生效。
+// 打印之前默认按照 defaultRedactor 脱敏，password、token 之类的字段会被替换掉，
+// 需要别的脱敏规则的话用 Redact 替换
 func NewInterceptorBuilder(l logger.Logger, reqBody, respBody bool) *InterceptorBuilder {
 	return &InterceptorBuilder{
-		l: l,
+		l:        l,
+		reqBody:  reqBody,
+		respBody: respBody,
+		redactor: defaultRedactor(),
 	}
 }
 
+// defaultRedactor 没有调用 Redact 的时候使用的脱敏规则
+func defaultRedactor() *logger.Redactor {
+	return logger.NewRedactor().
+		Keys(logger.MaskFull, "password", "passwd", "token", "access_token",
+			"refresh_token", "secret", "authorization").
+		Keys(logger.MaskPartial, "phone", "mobile")
+}
+
+// Redact 打印请求和响应之前先按照 r 脱敏，替换掉默认的脱敏规则。
+// 传入 nil 代表原样打印
+func (i *InterceptorBuilder) Redact(r *logger.Redactor) *InterceptorBuilder {
+	i.redactor = r
+	return i
+}
+
+// bodyFields 按照配置打印请求和响应
+func (i *InterceptorBuilder) bodyFields(req, resp any) []logger.Field {
+	var fields []logger.Field
+	if i.reqBody {
+		fields = append(fields, i.bodyField("req", req))
+	}
+	if i.respBody && resp != nil {
+		fields = append(fields, i.bodyField("resp", resp))
+	}
+	return fields
+}
+
+func (i *InterceptorBuilder) bodyField(key string, val any) logger.Field {
+	if i.redactor != nil {
+		val = i.redactor.Value(val)
+	}
+	return logger.Field{Key: key, Value: val}
+}
+
 func (i *InterceptorBuilder) BuildClient() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
 		start := time.Now()
@@ -59,7 +102,7 @@ func (i *InterceptorBuilder) BuildClient() grpc.UnaryClientInterceptor {
 					logger.String("peer_ip", i.PeerIP(ctx)),
 					logger.Bytes("stack", stack),
 				}
-				i.l.Info("RPC请求", fields...)
+				logger.Ctx(ctx, i.l).Info("RPC请求", fields...)
 				return
 			}
 			fields = []logger.Field{
@@ -73,6 +116,7 @@ func (i *InterceptorBuilder) BuildClient() grpc.UnaryClientInterceptor {
 				logger.String("peer", i.PeerName(ctx)),
 				logger.String("peer_ip", i.PeerIP(ctx)),
 			}
+			fields = append(fields, i.bodyFields(req, reply)...)
 
 			if err != nil {
 				st, _ := status.FromError(err)
@@ -81,7 +125,7 @@ func (i *InterceptorBuilder) BuildClient() grpc.UnaryClientInterceptor {
 					logger.String("code_msg", st.Message()))
 			}
 
-			i.l.Info("RPC请求", fields...)
+			logger.Ctx(ctx, i.l).Info("RPC请求", fields...)
 		}()
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
@@ -115,7 +159,7 @@ func (i *InterceptorBuilder) BuildServer() grpc.UnaryServerInterceptor {
 					logger.String("event", event),
 					logger.Bytes("stack", stack),
 				}
-				i.l.Error("RPC请求", fields...)
+				logger.Ctx(ctx, i.l).Error("RPC请求", fields...)
 				return
 			}
 			fields = []logger.Field{
@@ -129,6 +173,7 @@ func (i *InterceptorBuilder) BuildServer() grpc.UnaryServerInterceptor {
 				logger.String("peer", i.PeerName(ctx)),
 				logger.String("peer_ip", i.PeerIP(ctx)),
 			}
+			fields = append(fields, i.bodyFields(req, resp)...)
 			if err != nil {
 				st, _ := status.FromError(err)
 				fields = append(fields, logger.String("code",
@@ -136,7 +181,7 @@ func (i *InterceptorBuilder) BuildServer() grpc.UnaryServerInterceptor {
 					logger.String("code_msg", st.Message()))
 			}
 
-			i.l.Info("RPC请求", fields...)
+			logger.Ctx(ctx, i.l).Info("RPC请求", fields...)
 		}()
 		resp, err = handler(ctx, req)
 		return
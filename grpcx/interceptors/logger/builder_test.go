@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/Kirby980/go-pkg/logger/loggertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type loginReq struct {
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+func TestInterceptorBuilder_Body(t *testing.T) {
+	redactor := logger.NewRedactor().Keys(logger.MaskFull, "password")
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/Login"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return map[string]any{"token": "abc"}, nil
+	}
+	testCases := []struct {
+		name     string
+		builder  func(l logger.Logger) *InterceptorBuilder
+		wantReq  any
+		wantResp any
+	}{
+		{
+			name: "不打印请求和响应",
+			builder: func(l logger.Logger) *InterceptorBuilder {
+				return NewInterceptorBuilder(l, false, false)
+			},
+		},
+		{
+			name: "默认脱敏",
+			builder: func(l logger.Logger) *InterceptorBuilder {
+				return NewInterceptorBuilder(l, true, true)
+			},
+			wantReq:  map[string]any{"phone": "138******78", "password": "******"},
+			wantResp: map[string]any{"token": "******"},
+		},
+		{
+			name: "原样打印",
+			builder: func(l logger.Logger) *InterceptorBuilder {
+				return NewInterceptorBuilder(l, true, true).Redact(nil)
+			},
+			wantReq:  loginReq{Phone: "13812345678", Password: "123456"},
+			wantResp: map[string]any{"token": "abc"},
+		},
+		{
+			name: "脱敏",
+			builder: func(l logger.Logger) *InterceptorBuilder {
+				return NewInterceptorBuilder(l, true, false).Redact(redactor)
+			},
+			wantReq: map[string]any{"phone": "13812345678", "password": "******"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := loggertest.NewRecorder()
+			interceptor := tc.builder(rec).BuildServer()
+			_, err := interceptor(context.Background(),
+				loginReq{Phone: "13812345678", Password: "123456"}, info, handler)
+			require.NoError(t, err)
+			require.Equal(t, 1, rec.Len())
+			entry := rec.Entries()[0]
+			req, ok := entry.Field("req")
+			assert.Equal(t, tc.wantReq != nil, ok)
+			assert.Equal(t, tc.wantReq, req)
+			resp, ok := entry.Field("resp")
+			assert.Equal(t, tc.wantResp != nil, ok)
+			assert.Equal(t, tc.wantResp, resp)
+		})
+	}
+}
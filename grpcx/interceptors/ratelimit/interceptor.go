@@ -2,13 +2,18 @@ package ratelimit
 
 import (
 	"context"
+	"net"
 	"strings"
 
 	"github.com/Kirby980/go-pkg/logger"
 	"github.com/Kirby980/go-pkg/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type ctxLimitedKeyType string
@@ -16,9 +21,14 @@ type ctxLimitedKeyType string
 var ctxLimitedKey = ctxLimitedKeyType("limited")
 
 type InterceptorBuilder struct {
-	limiter *ratelimit.RedisSlidingWindowLimiter
+	limiter ratelimit.Limiter
 	key     string
 	l       logger.Logger
+	// 设置了之后服务端拦截器按照多条规则限流
+	rules  ratelimit.RuleLimiter
+	userID func(ctx context.Context) string
+	// BuildServerInterceptorService 每个服务单独计数
+	perService bool
 }
 
 // NewInterceptorBuilder 创建限流拦截器构建器
@@ -29,47 +39,84 @@ func NewInterceptorBuilder(key string, l logger.Logger) *InterceptorBuilder {
 	}
 }
 
-// WithLimiter 设置限流器
-func (b *InterceptorBuilder) WithLimiter(limiter *ratelimit.RedisSlidingWindowLimiter) *InterceptorBuilder {
+// WithLimiter 设置限流器，可以是 Redis 的，也可以是 ratelimit/local 里面的单机限流器
+func (b *InterceptorBuilder) WithLimiter(limiter ratelimit.Limiter) *InterceptorBuilder {
 	b.limiter = limiter
 	return b
 }
 
+// WithRules 服务端拦截器按照多条规则限流，Route 是完整的方法名，Header 读取 metadata，IP 来自 peer
+// 客户端拦截器不支持规则，还是需要 WithLimiter
+func (b *InterceptorBuilder) WithRules(l ratelimit.RuleLimiter) *InterceptorBuilder {
+	b.rules = l
+	return b
+}
+
+// PerService BuildServerInterceptorService 对所有的服务限流，每个服务单独计数，
+// key 是 key:服务名，例如 limiter:user.v1.UserService
+func (b *InterceptorBuilder) PerService() *InterceptorBuilder {
+	b.perService = true
+	return b
+}
+
+// UserID 从 ctx 里面拿到用户 ID，一般是鉴权拦截器放进去的，返回空字符串代表没有登录
+func (b *InterceptorBuilder) UserID(fn func(ctx context.Context) string) *InterceptorBuilder {
+	b.userID = fn
+	return b
+}
+
 // BuildServerInterceptor 构建服务端拦截器
+// 没有设置 WithLimiter 或者 WithRules 的时候 panic
 func (b *InterceptorBuilder) BuildServerInterceptor() grpc.UnaryServerInterceptor {
+	b.checkLimiter(true)
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-		limited, err := b.limiter.Limit(ctx, b.key)
+		var d ratelimit.Decision
+		if b.rules != nil {
+			d, err = b.decideRules(ctx, info.FullMethod)
+		} else {
+			d, err = ratelimit.Decide(ctx, b.limiter, b.key)
+		}
 		if err != nil {
 			b.l.Error("限流器错误", logger.Error(err))
 			return nil, status.Errorf(codes.Internal, "限流器错误")
 		}
-		if limited {
-			return nil, status.Errorf(codes.ResourceExhausted, "请求过多")
+		if !d.Allowed {
+			return nil, limitedError(d, "请求过多")
 		}
 		return handler(ctx, req)
 	}
 }
 
-// BuildClientInterceptor 构建客户端拦截器
+// BuildClientInterceptor 构建客户端拦截器，只使用 WithLimiter 设置的限流器
+// 没有设置 WithLimiter 的时候 panic，只设置了 WithRules 也一样
 func (b *InterceptorBuilder) BuildClientInterceptor() grpc.UnaryClientInterceptor {
+	b.checkLimiter(false)
 	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		limited, err := b.limiter.Limit(ctx, b.key)
+		d, err := ratelimit.Decide(ctx, b.limiter, b.key)
 		if err != nil {
 			b.l.Error("限流器错误", logger.Error(err))
 			return err
 		}
-		if limited {
-			return status.Errorf(codes.ResourceExhausted, "请求过多")
+		if !d.Allowed {
+			return limitedError(d, "请求过多")
 		}
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
 
 // BuildServerInterceptorDowngrade 构建服务端拦截器服务通知业务方进行限流，或者降级
+// 设置了 WithRules 的时候跟 BuildServerInterceptor 一样按照规则判定，
+// 没有设置 WithLimiter 或者 WithRules 的时候 panic
 func (b *InterceptorBuilder) BuildServerInterceptorDowngrade(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	b.checkLimiter(true)
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-		limiter, err := b.limiter.Limit(ctx, b.key)
-		if err != nil || limiter {
+		var d ratelimit.Decision
+		if b.rules != nil {
+			d, err = b.decideRules(ctx, info.FullMethod)
+		} else {
+			d, err = ratelimit.Decide(ctx, b.limiter, b.key)
+		}
+		if err != nil || !d.Allowed {
 			ctx = context.WithValue(ctx, ctxLimitedKey, true)
 		}
 		return handler(ctx, req)
@@ -77,19 +124,105 @@ func (b *InterceptorBuilder) BuildServerInterceptorDowngrade(limiter *ratelimit.
 }
 
 // BuildServerInterceptorService 服务级别限流
+// 默认只限制 /UserService 开头的方法，key 固定是 limiter:service:user:UserService，跟以前一样；
+// 设置了 PerService 的时候对所有的服务限流，每个服务单独计数；
+// 设置了 WithRules 的时候按照规则限流，需要按服务限流的话用 Match 匹配服务的前缀。
+// 没有设置 WithLimiter 或者 WithRules 的时候 panic
 func (b *InterceptorBuilder) BuildServerInterceptorService() grpc.UnaryServerInterceptor {
+	b.checkLimiter(true)
 	return func(ctx context.Context, req any,
 		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-		if strings.HasPrefix(info.FullMethod, "/UserService") {
-			limited, err := b.limiter.Limit(ctx, "limiter:service:user:UserService")
-			if err != nil {
-				b.l.Error("判定限流出现问题", logger.Error(err))
-				return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
-			}
-			if limited {
-				return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
-			}
+		var d ratelimit.Decision
+		switch {
+		case b.rules != nil:
+			d, err = b.decideRules(ctx, info.FullMethod)
+		case b.perService:
+			d, err = ratelimit.Decide(ctx, b.limiter, b.key+":"+serviceName(info.FullMethod))
+		case strings.HasPrefix(info.FullMethod, "/UserService"):
+			d, err = ratelimit.Decide(ctx, b.limiter, "limiter:service:user:UserService")
+		default:
+			return handler(ctx, req)
+		}
+		if err != nil {
+			b.l.Error("判定限流出现问题", logger.Error(err))
+			return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+		}
+		if !d.Allowed {
+			return nil, limitedError(d, "触发限流")
 		}
 		return handler(ctx, req)
 	}
 }
+
+// checkLimiter 构建的时候就检查，而不是等到第一个请求进来的时候 panic
+// rules 为 false 代表不支持规则，必须设置 WithLimiter
+func (b *InterceptorBuilder) checkLimiter(rules bool) {
+	if b.limiter != nil || (rules && b.rules != nil) {
+		return
+	}
+	if rules {
+		panic("ratelimit: 需要 WithLimiter 或者 WithRules")
+	}
+	panic("ratelimit: 客户端拦截器需要 WithLimiter，WithRules 只对服务端拦截器生效")
+}
+
+func (b *InterceptorBuilder) decideRules(ctx context.Context, fullMethod string) (ratelimit.Decision, error) {
+	req := ratelimit.Request{
+		Route: fullMethod,
+		IP:    peerIP(ctx),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		req.Header = func(name string) string {
+			vals := md.Get(name)
+			if len(vals) == 0 {
+				return ""
+			}
+			return vals[0]
+		}
+	}
+	if b.userID != nil {
+		req.UserID = b.userID(ctx)
+	}
+	d, err := b.rules.DecideRequest(ctx, req)
+	if err == nil && !d.Allowed && d.Rule != "" {
+		b.l.Warn("触发限流", logger.String("rule", d.Rule), logger.String("method", fullMethod))
+	}
+	return d.Decision, err
+}
+
+// serviceName 从 /user.v1.UserService/GetByID 里面拿到 user.v1.UserService
+func serviceName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// limitedError 返回 ResourceExhausted，知道什么时候可以重试的话带上 RetryInfo，
+// 客户端可以用 status.Details 拿到
+func limitedError(d ratelimit.Decision, msg string) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	if d.RetryAfter <= 0 {
+		return st.Err()
+	}
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(d.RetryAfter),
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/Kirby980/go-pkg/ratelimit"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestInterceptorBuilder_RetryInfo(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(client, time.Minute, 1)
+	interceptor := NewInterceptorBuilder("limiter:test", &logger.NopLogger{}).
+		WithLimiter(limiter).BuildServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/UserService/Select"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	resp, err := interceptor(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(context.Background(), nil, info, handler)
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Greater(t, retry.RetryDelay.AsDuration(), time.Second*59)
+	assert.LessOrEqual(t, retry.RetryDelay.AsDuration(), time.Minute)
+}
+
+func TestInterceptorBuilder_Service(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(client, time.Minute, 1)
+	interceptor := NewInterceptorBuilder("limiter:service", &logger.NopLogger{}).
+		WithLimiter(limiter).PerService().BuildServerInterceptorService()
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	_, err := interceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetByID"}, handler)
+	require.NoError(t, err)
+	// 同一个服务的其他方法共用额度
+	_, err = interceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/Edit"}, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// 其他服务单独计数
+	_, err = interceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/article.v1.ArticleService/List"}, handler)
+	require.NoError(t, err)
+}
+
+func TestInterceptorBuilder_ServiceDefault(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(client, time.Minute, 1)
+	interceptor := NewInterceptorBuilder("limiter:service", &logger.NopLogger{}).
+		WithLimiter(limiter).BuildServerInterceptorService()
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	// 默认只限制 /UserService，key 跟以前一样
+	_, err := interceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/UserService/GetByID"}, handler)
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("limiter:service:user:UserService"))
+	_, err = interceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/UserService/Edit"}, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// 其他服务不限流
+	for i := 0; i < 3; i++ {
+		_, err = interceptor(context.Background(), nil,
+			&grpc.UnaryServerInfo{FullMethod: "/article.v1.ArticleService/List"}, handler)
+		require.NoError(t, err)
+	}
+}
+
+func TestInterceptorBuilder_Rules(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	rules, err := ratelimit.NewRedisRuleLimiter(client, "limiter:rules",
+		ratelimit.Rule{Name: "app", Dimension: ratelimit.DimHeader, Header: "x-app-key", Interval: time.Minute, Rate: 1})
+	require.NoError(t, err)
+	interceptor := NewInterceptorBuilder("limiter:rules", &logger.NopLogger{}).
+		WithRules(rules).BuildServerInterceptorService()
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetByID"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-app-key", "app1"))
+
+	_, err = interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// 没有带 app key 的请求不受这条规则限制
+	_, err = interceptor(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+}
+
+func TestInterceptorBuilder_RulesDowngrade(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	rules, err := ratelimit.NewRedisRuleLimiter(client, "limiter:rules",
+		ratelimit.Rule{Name: "global", Dimension: ratelimit.DimGlobal, Interval: time.Minute, Rate: 1})
+	require.NoError(t, err)
+	builder := NewInterceptorBuilder("limiter:rules", &logger.NopLogger{}).WithRules(rules)
+	// 只有规则的时候客户端拦截器没法限流，构建的时候就报出来
+	assert.Panics(t, func() {
+		builder.BuildClientInterceptor()
+	})
+
+	interceptor := builder.BuildServerInterceptorDowngrade(nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetByID"}
+	var limited []bool
+	handler := func(ctx context.Context, req any) (any, error) {
+		val, _ := ctx.Value(ctxLimitedKey).(bool)
+		limited = append(limited, val)
+		return "ok", nil
+	}
+	for i := 0; i < 2; i++ {
+		_, err = interceptor(context.Background(), nil, info, handler)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []bool{false, true}, limited)
+}
+
+func TestSemaphoreInterceptorBuilder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	sem := ratelimit.NewRedisSemaphore(client, 1).PollInterval(time.Millisecond * 10)
+	holder, err := sem.TryAcquire(context.Background(), "semaphore:openim")
+	require.NoError(t, err)
+
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	reject := NewSemaphoreInterceptorBuilder(sem, "semaphore:openim", &logger.NopLogger{}).BuildClientInterceptor()
+	err = reject(context.Background(), "/openim/Send", nil, nil, nil, invoker)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// 排队等待，持有者释放之后拿到许可
+	wait := NewSemaphoreInterceptorBuilder(sem, "semaphore:openim", &logger.NopLogger{}).
+		Wait(time.Second).BuildClientInterceptor()
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = holder.Release(context.Background())
+	}()
+	err = wait(context.Background(), "/openim/Send", nil, nil, nil, invoker)
+	require.NoError(t, err)
+}
+
+func TestSemaphoreInterceptorBuilder_KeepAlive(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	sem := ratelimit.NewRedisSemaphore(client, 1).Lease(time.Millisecond * 150)
+	interceptor := NewSemaphoreInterceptorBuilder(sem, "semaphore:openim", &logger.NopLogger{}).BuildServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/openim/Send"}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			// 处理的时间超过了租约
+			time.Sleep(time.Millisecond * 400)
+			return nil, nil
+		})
+		done <- err
+	}()
+	// 租约过期之后许可还在第一个请求手里
+	time.Sleep(time.Millisecond * 300)
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NoError(t, <-done)
+
+	// 处理完之后许可被释放了
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
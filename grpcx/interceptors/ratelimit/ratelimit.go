@@ -34,6 +34,8 @@ func (l *CounterLimiter) NewServerInterceptor() grpc.UnaryServerInterceptor {
 }
 
 // FixedWindowLimiter 固定窗口算法
+//
+// Deprecated: 只能做全局限流，使用 ratelimit/local 里面按 key 限流的 local.FixedWindowLimiter，配合 InterceptorBuilder.WithLimiter
 type FixedWindowLimiter struct {
 	// 窗口大小
 	window time.Duration
@@ -70,6 +72,8 @@ func (l *FixedWindowLimiter) NewServerInterceptor() grpc.UnaryServerInterceptor
 }
 
 // SlideWindowLimiter 滑动窗口算法
+//
+// Deprecated: 只能做全局限流，使用 ratelimit/local 里面按 key 限流的 local.SlidingWindowLimiter，配合 InterceptorBuilder.WithLimiter
 type SlideWindowLimiter struct {
 	window time.Duration
 	// 请求的时间戳
@@ -110,6 +114,8 @@ func (l *SlideWindowLimiter) NewServerInterceptor() grpc.UnaryServerInterceptor
 }
 
 // TokenBucketLimiter 令牌桶算法
+//
+// Deprecated: 只能做全局限流，使用 ratelimit/local 里面按 key 限流的 local.TokenBucketLimiter，配合 InterceptorBuilder.WithLimiter
 type TokenBucketLimiter struct {
 	//ch      *time.Ticker
 	buckets chan struct{}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/Kirby980/go-pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SemaphoreInterceptorBuilder 限制整个集群同时在处理的请求数。
+// 服务端拦截器保护自己，客户端拦截器保护下游，例如 OpenIM
+type SemaphoreInterceptorBuilder struct {
+	sem ratelimit.Semaphore
+	key string
+	// 0 代表没有许可的时候直接拒绝
+	wait time.Duration
+	l    logger.Logger
+}
+
+func NewSemaphoreInterceptorBuilder(sem ratelimit.Semaphore, key string, l logger.Logger) *SemaphoreInterceptorBuilder {
+	return &SemaphoreInterceptorBuilder{
+		sem: sem,
+		key: key,
+		l:   l,
+	}
+}
+
+// Wait 没有许可的时候最多排队等多久，默认不等待，直接返回 ResourceExhausted
+func (b *SemaphoreInterceptorBuilder) Wait(d time.Duration) *SemaphoreInterceptorBuilder {
+	b.wait = d
+	return b
+}
+
+// BuildServerInterceptor 构建服务端拦截器
+func (b *SemaphoreInterceptorBuilder) BuildServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		permit, err := b.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer b.keepAlive(permit)()
+		return handler(ctx, req)
+	}
+}
+
+// BuildClientInterceptor 构建客户端拦截器
+func (b *SemaphoreInterceptorBuilder) BuildClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		permit, err := b.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer b.keepAlive(permit)()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (b *SemaphoreInterceptorBuilder) acquire(ctx context.Context) (ratelimit.Permit, error) {
+	var (
+		permit ratelimit.Permit
+		err    error
+	)
+	if b.wait <= 0 {
+		permit, err = b.sem.TryAcquire(ctx, b.key)
+	} else {
+		wctx, cancel := context.WithTimeout(ctx, b.wait)
+		permit, err = b.sem.Acquire(wctx, b.key)
+		cancel()
+	}
+	switch {
+	case err == nil:
+		return permit, nil
+	case errors.Is(err, ratelimit.ErrNoPermit), errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Errorf(codes.ResourceExhausted, "并发请求过多")
+	case errors.Is(err, context.Canceled):
+		return nil, status.FromContextError(err).Err()
+	default:
+		b.l.Error("信号量错误", logger.Error(err))
+		return nil, status.Errorf(codes.Internal, "信号量错误")
+	}
+}
+
+// keepAlive 处理的时间可能超过租约，处理完之前一直续约，返回的函数停止续约并且释放许可
+func (b *SemaphoreInterceptorBuilder) keepAlive(permit ratelimit.Permit) func() {
+	stop := ratelimit.KeepAlive(permit, func(err error) {
+		b.l.Error("信号量续约失败", logger.Error(err))
+	})
+	return func() {
+		stop()
+		b.release(permit)
+	}
+}
+
+// release 请求的 ctx 可能已经被取消了，释放许可用新的 ctx
+func (b *SemaphoreInterceptorBuilder) release(permit ratelimit.Permit) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := permit.Release(ctx); err != nil && !errors.Is(err, ratelimit.ErrPermitLost) {
+		b.l.Error("释放信号量失败", logger.Error(err))
+	}
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import "iter"
+
+// 基于 iter.Seq 的流式处理工具
+// 除了 Reduce 和 GroupBy 这种终结操作，其余函数都是惰性的，
+// 只有在 range 的时候才会真正去拉取上游数据，不会在每一步都分配中间切片
+// 切片可以通过 slices.Values 转换成 iter.Seq
+
+// Map 将 seq 中的每个元素转换为另一个类型
+func Map[T any, U any](seq iter.Seq[T], fn func(T) U) iter.Seq[U] {
+	return func(yield func(U) bool) {
+		for v := range seq {
+			if !yield(fn(v)) {
+				return
+			}
+		}
+	}
+}
+
+// Filter 只保留 pred 返回 true 的元素
+func Filter[T any](seq iter.Seq[T], pred func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if pred(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Reduce 从 init 开始依次用 fn 聚合 seq 中的元素
+func Reduce[T any, A any](seq iter.Seq[T], init A, fn func(acc A, v T) A) A {
+	acc := init
+	for v := range seq {
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+// Chunk 将 seq 按 size 分批，最后一批可能不足 size
+// 每一批都是新分配的切片，可以放心持有
+func Chunk[T any](seq iter.Seq[T], size int) iter.Seq[[]T] {
+	if size <= 0 {
+		size = 1
+	}
+	return func(yield func([]T) bool) {
+		batch := make([]T, 0, size)
+		for v := range seq {
+			batch = append(batch, v)
+			if len(batch) == size {
+				if !yield(batch) {
+					return
+				}
+				batch = make([]T, 0, size)
+			}
+		}
+		if len(batch) > 0 {
+			yield(batch)
+		}
+	}
+}
+
+// GroupBy 按 key 对 seq 分组，组内保持原有顺序
+// 这是一个终结操作，会消费整个 seq
+func GroupBy[T any, K comparable](seq iter.Seq[T], key func(T) K) map[K][]T {
+	res := make(map[K][]T)
+	for v := range seq {
+		k := key(v)
+		res[k] = append(res[k], v)
+	}
+	return res
+}
+
+// Distinct 去重，保留第一次出现的元素
+func Distinct[T comparable](seq iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		seen := make(map[T]struct{})
+		for v := range seq {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Zip 将两个 seq 按位置配对，任意一个结束就结束
+func Zip[A any, B any](a iter.Seq[A], b iter.Seq[B]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		next, stop := iter.Pull(b)
+		defer stop()
+		for va := range a {
+			vb, ok := next()
+			if !ok || !yield(va, vb) {
+				return
+			}
+		}
+	}
+}
+
+// Take 只取 seq 的前 n 个元素
+func Take[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+			i++
+			if i >= n {
+				return
+			}
+		}
+	}
+}
+
+// Flatten 将一批一批的切片展开成单个元素，和 Chunk 相反
+func Flatten[T any](seq iter.Seq[[]T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for batch := range seq {
+			for _, v := range batch {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
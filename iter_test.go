@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterChain(t *testing.T) {
+	src := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	seq := Map(Filter(slices.Values(src), func(v int) bool {
+		return v%2 == 0
+	}), func(v int) string {
+		return strconv.Itoa(v)
+	})
+	assert.Equal(t, []string{"2", "4", "6", "8", "10"}, slices.Collect(seq))
+
+	sum := Reduce(slices.Values(src), 0, func(acc int, v int) int {
+		return acc + v
+	})
+	assert.Equal(t, 55, sum)
+
+	assert.Equal(t, []int{1, 2, 3}, slices.Collect(Take(slices.Values(src), 3)))
+	assert.Empty(t, slices.Collect(Take(slices.Values(src), 0)))
+}
+
+func TestChunkAndFlatten(t *testing.T) {
+	src := []int{1, 2, 3, 4, 5, 6, 7}
+	chunks := slices.Collect(Chunk(slices.Values(src), 3))
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, chunks)
+	assert.Equal(t, src, slices.Collect(Flatten(slices.Values(chunks))))
+
+	// 提前 break 不会继续拉取上游
+	pulled := 0
+	counting := Map(slices.Values(src), func(v int) int {
+		pulled++
+		return v
+	})
+	for range Chunk(counting, 2) {
+		break
+	}
+	assert.Equal(t, 2, pulled)
+}
+
+func TestGroupByAndDistinct(t *testing.T) {
+	src := []string{"apple", "avocado", "banana", "blueberry", "cherry", "apple"}
+	groups := GroupBy(slices.Values(src), func(s string) byte {
+		return s[0]
+	})
+	assert.Equal(t, map[byte][]string{
+		'a': {"apple", "avocado", "apple"},
+		'b': {"banana", "blueberry"},
+		'c': {"cherry"},
+	}, groups)
+
+	assert.Equal(t, []string{"apple", "avocado", "banana", "blueberry", "cherry"},
+		slices.Collect(Distinct(slices.Values(src))))
+}
+
+func TestZip(t *testing.T) {
+	ids := []int64{1, 2, 3}
+	names := []string{"a", "b"}
+	var res []string
+	for id, name := range Zip(slices.Values(ids), slices.Values(names)) {
+		res = append(res, strconv.FormatInt(id, 10)+name)
+	}
+	assert.Equal(t, []string{"1a", "2b"}, res)
+}
+
+func TestQueueAll(t *testing.T) {
+	var q Queue[int]
+	var rq RrQueue[int]
+	for i := 0; i < 20; i++ {
+		q.Enqueue(i)
+		rq.Enqueue(i)
+	}
+	for i := 0; i < 5; i++ {
+		q.Dequeue()
+		rq.Dequeue()
+	}
+	want := []int{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+	assert.Equal(t, want, slices.Collect(q.All()))
+	assert.Equal(t, want, slices.Collect(rq.All()))
+
+	// 遍历过程中修改队列不会死锁
+	for v := range q.All() {
+		if v == 5 {
+			q.Enqueue(100)
+		}
+	}
+	assert.Equal(t, 16, q.Len())
+}
@@ -0,0 +1,269 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowPolicy 缓冲区满了之后怎么处理新的日志
+type OverflowPolicy int8
+
+const (
+	// OverflowBlock 等待后台写出去腾出位置
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest 丢弃新的日志
+	OverflowDropNewest
+	// OverflowDropOldest 丢弃缓冲区里最老的日志，给新的腾出位置
+	OverflowDropOldest
+	// OverflowDropBelowLevel 丢弃低于 DropBelow 级别的新日志，级别够的等待
+	OverflowDropBelowLevel
+)
+
+// AsyncLogger 装饰器模式
+// 日志先放到有界的环形缓冲区，由后台的 goroutine 写到被装饰的 Logger，
+// 避免日志收集卡住的时候拖慢业务。因为是在后台 goroutine 里写的，caller 会指向 AsyncLogger，
+// 需要行号的话不要开启 Caller，或者直接使用同步的 Logger
+type AsyncLogger struct {
+	l Logger
+	q *asyncQueue
+}
+
+type asyncEntry struct {
+	l      Logger
+	level  Level
+	msg    string
+	fields []Field
+}
+
+type asyncQueue struct {
+	policy    OverflowPolicy
+	dropBelow Level
+	counter   *prometheus.CounterVec
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buf      []asyncEntry
+	head     int
+	size     int
+	// 放进来的条数和已经处理完的条数，被 OverflowDropOldest 挤掉的也算处理完了
+	enqueued  uint64
+	processed uint64
+	// 每处理完一批就关闭再换一个新的，通知 Sync
+	progress chan struct{}
+	closed   bool
+	done     chan struct{}
+
+	dropped [ErrorLevel + 1]atomic.Uint64
+}
+
+// NewAsyncLogger 创建异步 Logger，capacity 是缓冲区能放多少条日志
+// 用完之后需要调用 Close，把缓冲区里的日志写完
+func NewAsyncLogger(l Logger, capacity int, policy OverflowPolicy) *AsyncLogger {
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	q := &asyncQueue{
+		policy:    policy,
+		dropBelow: WarnLevel,
+		buf:       make([]asyncEntry, capacity),
+		progress:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	go q.loop()
+	return &AsyncLogger{l: l, q: q}
+}
+
+// DropBelow 设置 OverflowDropBelowLevel 的级别，默认是 Warn，也就是满了之后丢弃 Debug 和 Info
+// 需要在打日志之前设置
+func (a *AsyncLogger) DropBelow(level Level) *AsyncLogger {
+	a.q.dropBelow = level
+	return a
+}
+
+// DroppedCounter 丢弃日志的时候同时记录到 prometheus，c 需要有一个 level 标签
+// 需要在打日志之前设置
+func (a *AsyncLogger) DroppedCounter(c *prometheus.CounterVec) *AsyncLogger {
+	a.q.counter = c
+	return a
+}
+
+// With implements Logger.
+func (a *AsyncLogger) With(args ...Field) Logger {
+	return &AsyncLogger{l: a.l.With(args...), q: a.q}
+}
+
+// WithContext implements LoggerV2.
+func (a *AsyncLogger) WithContext(ctx context.Context) Logger {
+	return &AsyncLogger{l: Ctx(ctx, a.l), q: a.q}
+}
+
+func (a *AsyncLogger) Debug(msg string, args ...Field) {
+	a.q.put(asyncEntry{l: a.l, level: DebugLevel, msg: msg, fields: args})
+}
+
+func (a *AsyncLogger) Info(msg string, args ...Field) {
+	a.q.put(asyncEntry{l: a.l, level: InfoLevel, msg: msg, fields: args})
+}
+
+func (a *AsyncLogger) Warn(msg string, args ...Field) {
+	a.q.put(asyncEntry{l: a.l, level: WarnLevel, msg: msg, fields: args})
+}
+
+func (a *AsyncLogger) Error(msg string, args ...Field) {
+	a.q.put(asyncEntry{l: a.l, level: ErrorLevel, msg: msg, fields: args})
+}
+
+// Dropped 返回各个级别被丢弃的日志条数
+func (a *AsyncLogger) Dropped() map[Level]uint64 {
+	res := make(map[Level]uint64, len(a.q.dropped))
+	for i := range a.q.dropped {
+		res[Level(i)] = a.q.dropped[i].Load()
+	}
+	return res
+}
+
+// Buffered 返回缓冲区里还没写出去的条数
+func (a *AsyncLogger) Buffered() int {
+	a.q.mu.Lock()
+	defer a.q.mu.Unlock()
+	return a.q.size
+}
+
+// Sync 等待调用之前放进来的日志都写出去，被装饰的 Logger 有 Sync 方法的话也会调用
+func (a *AsyncLogger) Sync(ctx context.Context) error {
+	q := a.q
+	q.mu.Lock()
+	target := q.enqueued
+	for q.processed < target {
+		ch := q.progress
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+		q.mu.Lock()
+	}
+	q.mu.Unlock()
+	return a.syncUnderlying()
+}
+
+// Close 不再接收新的日志，等待缓冲区里的日志都写出去
+// Close 之后打的日志会直接同步写到被装饰的 Logger
+func (a *AsyncLogger) Close() error {
+	q := a.q
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	q.mu.Unlock()
+	<-q.done
+	return a.syncUnderlying()
+}
+
+func (a *AsyncLogger) syncUnderlying() error {
+	if s, ok := a.l.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func (q *asyncQueue) put(e asyncEntry) {
+	// 调用方可能会复用切片，这里复制一份
+	if len(e.fields) > 0 {
+		e.fields = append([]Field(nil), e.fields...)
+	}
+	q.mu.Lock()
+	for !q.closed && q.size == len(q.buf) {
+		switch {
+		case q.policy == OverflowDropNewest,
+			q.policy == OverflowDropBelowLevel && e.level < q.dropBelow:
+			q.mu.Unlock()
+			q.drop(e.level)
+			return
+		case q.policy == OverflowDropOldest:
+			old := q.buf[q.head]
+			q.buf[q.head] = asyncEntry{}
+			q.head = (q.head + 1) % len(q.buf)
+			q.size--
+			q.processed++
+			q.drop(old.level)
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		q.mu.Unlock()
+		e.write()
+		return
+	}
+	q.buf[(q.head+q.size)%len(q.buf)] = e
+	q.size++
+	q.enqueued++
+	q.notEmpty.Signal()
+	q.mu.Unlock()
+}
+
+func (q *asyncQueue) drop(level Level) {
+	q.dropped[level].Add(1)
+	if q.counter != nil {
+		q.counter.WithLabelValues(level.String()).Inc()
+	}
+}
+
+// loop 每次把缓冲区里的日志全部取出来，在锁外面写
+func (q *asyncQueue) loop() {
+	defer close(q.done)
+	batch := make([]asyncEntry, 0, len(q.buf))
+	for {
+		q.mu.Lock()
+		for q.size == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.size == 0 && q.closed {
+			q.mu.Unlock()
+			return
+		}
+		for q.size > 0 {
+			batch = append(batch, q.buf[q.head])
+			q.buf[q.head] = asyncEntry{}
+			q.head = (q.head + 1) % len(q.buf)
+			q.size--
+		}
+		q.notFull.Broadcast()
+		q.mu.Unlock()
+
+		for i := range batch {
+			batch[i].write()
+			batch[i] = asyncEntry{}
+		}
+
+		q.mu.Lock()
+		q.processed += uint64(len(batch))
+		close(q.progress)
+		q.progress = make(chan struct{})
+		q.mu.Unlock()
+		batch = batch[:0]
+	}
+}
+
+func (e asyncEntry) write() {
+	switch e.level {
+	case DebugLevel:
+		e.l.Debug(e.msg, e.fields...)
+	case InfoLevel:
+		e.l.Info(e.msg, e.fields...)
+	case WarnLevel:
+		e.l.Warn(e.msg, e.fields...)
+	default:
+		e.l.Error(e.msg, e.fields...)
+	}
+}
@@ -0,0 +1,248 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// gateLogger 写日志之前先通知 entered，然后等 gate 放行，用来模拟卡住的日志收集
+type gateLogger struct {
+	Logger
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func newGateLogger() (*gateLogger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return &gateLogger{
+		Logger:  NewZapLogger(zap.New(core)),
+		entered: make(chan struct{}, 100),
+		gate:    make(chan struct{}),
+	}, logs
+}
+
+func (g *gateLogger) wait() {
+	g.entered <- struct{}{}
+	<-g.gate
+}
+
+func (g *gateLogger) Debug(msg string, args ...Field) { g.wait(); g.Logger.Debug(msg, args...) }
+func (g *gateLogger) Info(msg string, args ...Field)  { g.wait(); g.Logger.Info(msg, args...) }
+func (g *gateLogger) Warn(msg string, args ...Field)  { g.wait(); g.Logger.Warn(msg, args...) }
+func (g *gateLogger) Error(msg string, args ...Field) { g.wait(); g.Logger.Error(msg, args...) }
+
+func messages(logs *observer.ObservedLogs) []string {
+	var res []string
+	for _, e := range logs.All() {
+		res = append(res, e.Message)
+	}
+	return res
+}
+
+func TestAsyncLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewAsyncLogger(NewZapLogger(zap.New(core)), 16, OverflowBlock)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wl := l.With(Int64("worker", int64(i)))
+			for j := 0; j < 100; j++ {
+				wl.Info("msg")
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, l.Sync(context.Background()))
+	assert.Equal(t, 1000, logs.Len())
+	assert.Equal(t, 100, logs.FilterField(zap.Int64("worker", 3)).Len())
+	require.NoError(t, l.Close())
+
+	// Close 之后同步写
+	l.Error("after close")
+	assert.Equal(t, 1001, logs.Len())
+}
+
+// syncWriter 记录 Sync 被调用了多少次
+type syncWriter struct {
+	mu    sync.Mutex
+	lines int
+	syncs int
+	err   error
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines++
+	return len(p), nil
+}
+
+func (w *syncWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.syncs++
+	return w.err
+}
+
+func TestAsyncLogger_SyncCore(t *testing.T) {
+	w := &syncWriter{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), w, zapcore.DebugLevel)
+	l := NewAsyncLogger(NewZapLogger(zap.New(core)), 16, OverflowBlock)
+	l.Info("msg")
+	require.NoError(t, l.Sync(context.Background()))
+	// 缓冲区里的日志写完之后，Sync 一直传到了 zap 的输出
+	assert.Equal(t, 1, w.lines)
+	assert.Equal(t, 1, w.syncs)
+	require.NoError(t, l.Close())
+	assert.Equal(t, 2, w.syncs)
+}
+
+func TestZapLogger_Sync(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{
+			name: "stdout 返回 EINVAL",
+			err:  &os.PathError{Op: "sync", Path: "/dev/stdout", Err: syscall.EINVAL},
+		},
+		{
+			name: "终端返回 ENOTTY",
+			err:  &os.PathError{Op: "sync", Path: "/dev/stderr", Err: syscall.ENOTTY},
+		},
+		{
+			name:    "其它错误",
+			err:     syscall.EIO,
+			wantErr: syscall.EIO,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &syncWriter{err: tc.err}
+			core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), w, zapcore.DebugLevel)
+			l := NewAsyncLogger(NewZapLogger(zap.New(core)), 16, OverflowBlock)
+			l.Info("msg")
+			err := l.Close()
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestAsyncLogger_Overflow(t *testing.T) {
+	testCases := []struct {
+		name    string
+		policy  OverflowPolicy
+		wantMsg []string
+		dropped map[Level]uint64
+	}{
+		{
+			name:    "drop newest",
+			policy:  OverflowDropNewest,
+			wantMsg: []string{"0", "1", "2"},
+			dropped: map[Level]uint64{DebugLevel: 1, InfoLevel: 2},
+		},
+		{
+			name:    "drop oldest",
+			policy:  OverflowDropOldest,
+			wantMsg: []string{"0", "4", "debug"},
+			dropped: map[Level]uint64{InfoLevel: 3},
+		},
+		{
+			name:   "drop below level",
+			policy: OverflowDropBelowLevel,
+			// warn 不会被丢弃，等到有位置了再放进去
+			wantMsg: []string{"0", "1", "2", "warn"},
+			dropped: map[Level]uint64{DebugLevel: 1, InfoLevel: 2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gl, logs := newGateLogger()
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"level"})
+			l := NewAsyncLogger(gl, 2, tc.policy).DroppedCounter(counter)
+			l.Info("0")
+			// 后台卡在写第一条上
+			<-gl.entered
+			for i := 1; i <= 4; i++ {
+				l.Info(strconv.Itoa(i))
+			}
+			l.Debug("debug")
+
+			warnDone := make(chan struct{})
+			if tc.policy == OverflowDropBelowLevel {
+				go func() {
+					defer close(warnDone)
+					l.Warn("warn")
+				}()
+				select {
+				case <-warnDone:
+					t.Fatal("warn should wait when buffer is full")
+				case <-time.After(time.Millisecond * 20):
+				}
+			} else {
+				close(warnDone)
+			}
+			close(gl.gate)
+			<-warnDone
+			require.NoError(t, l.Close())
+
+			assert.Equal(t, tc.wantMsg, messages(logs))
+			dropped := l.Dropped()
+			for _, lvl := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel} {
+				assert.Equal(t, tc.dropped[lvl], dropped[lvl], lvl.String())
+			}
+			assert.Equal(t, float64(tc.dropped[InfoLevel]), testutil.ToFloat64(counter.WithLabelValues("info")))
+		})
+	}
+}
+
+func TestAsyncLogger_Block(t *testing.T) {
+	gl, logs := newGateLogger()
+	l := NewAsyncLogger(gl, 1, OverflowBlock)
+	l.Info("0")
+	<-gl.entered
+	l.Info("1")
+
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		l.Info("2")
+	}()
+	select {
+	case <-blocked:
+		t.Fatal("should block when buffer is full")
+	case <-time.After(time.Millisecond * 50):
+	}
+	assert.Equal(t, 1, l.Buffered())
+
+	// 卡住的时候 Sync 会超时
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.ErrorIs(t, l.Sync(ctx), context.DeadlineExceeded)
+
+	close(gl.gate)
+	<-blocked
+	require.NoError(t, l.Sync(context.Background()))
+	assert.Equal(t, []string{"0", "1", "2"}, messages(logs))
+	require.NoError(t, l.Close())
+}
@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config 日志配置，可以直接从配置文件里面解析出来
+//
+//	service: user
+//	level: info
+//	encoder: json
+//	caller: true
+//	outputs:
+//	  - type: stdout
+//	  - type: stderr
+//	    level: error
+//	  - type: file
+//	    file:
+//	      filename: /var/log/user/app.log
+//	      max_size: 100
+//	      interval: 24h
+//	      max_backups: 7
+//	      compress: true
+type Config struct {
+	// 服务名，不为空的话每条日志都会带上 service 字段
+	Service string `json:"service" yaml:"service"`
+	// debug、info、warn、error，默认 info
+	Level string `json:"level" yaml:"level"`
+	// json 或者 console，默认 json
+	Encoder string `json:"encoder" yaml:"encoder"`
+	// 是否输出调用方的文件和行号
+	Caller bool `json:"caller" yaml:"caller"`
+	// 输出的位置，为空的话输出到 stdout
+	Outputs []OutputConfig `json:"outputs" yaml:"outputs"`
+}
+
+// OutputConfig 一个输出的位置
+type OutputConfig struct {
+	// stdout、stderr 或者 file
+	Type string `json:"type" yaml:"type"`
+	// 这个输出的最低级别，比 Config.Level 低的话不生效
+	// 为空的时候跟随 Config.Level，stderr 默认只输出 error
+	Level string `json:"level" yaml:"level"`
+	// type 为 file 的时候使用
+	File RotateConfig `json:"file" yaml:"file"`
+}
+
+// NewLogger 根据配置创建 Logger
+// 返回的 cleanup 会把缓冲的日志刷出去并且关闭文件，可以直接作为 wire 的 provider
+func NewLogger(cfg Config) (Logger, func(), error) {
+	l, cleanup, err := NewZap(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewZapLogger(l), cleanup, nil
+}
+
+// NewZap 根据配置创建 zap.Logger
+// 开启 Caller 的时候会跳过一层调用，保证通过 ZapLogger 调用的时候行号是对的
+func NewZap(cfg Config) (*zap.Logger, func(), error) {
+	base := zapcore.InfoLevel
+	if cfg.Level != "" {
+		lvl, err := parseLevel(cfg.Level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("logger: invalid level %q: %w", cfg.Level, err)
+		}
+		base = lvl
+	}
+	encoder, err := newEncoder(cfg.Encoder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{Type: "stdout"}}
+	}
+	var (
+		cores   = make([]zapcore.Core, 0, len(outputs))
+		closers []*RotateWriter
+	)
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}
+	for _, out := range outputs {
+		ws, lvl, err := newOutput(out, base)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if rw, ok := ws.(*RotateWriter); ok {
+			closers = append(closers, rw)
+		}
+		cores = append(cores, zapcore.NewCore(encoder.Clone(), ws, lvl))
+	}
+
+	opts := make([]zap.Option, 0, 3)
+	if cfg.Caller {
+		opts = append(opts, zap.AddCaller(), zap.AddCallerSkip(1))
+	}
+	if cfg.Service != "" {
+		opts = append(opts, zap.Fields(zap.String("service", cfg.Service)))
+	}
+	l := zap.New(zapcore.NewTee(cores...), opts...)
+	cleanup := func() {
+		_ = l.Sync()
+		closeAll()
+	}
+	return l, cleanup, nil
+}
+
+func newEncoder(typ string) (zapcore.Encoder, error) {
+	switch typ {
+	case "", "json":
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	case "console":
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	default:
+		return nil, fmt.Errorf("logger: unknown encoder %q", typ)
+	}
+}
+
+// newOutput 返回输出的位置和最低级别
+func newOutput(out OutputConfig, base zapcore.Level) (zapcore.WriteSyncer, zapcore.Level, error) {
+	lvl := base
+	if out.Type == "stderr" {
+		lvl = zapcore.ErrorLevel
+	}
+	if out.Level != "" {
+		l, err := parseLevel(out.Level)
+		if err != nil {
+			return nil, lvl, fmt.Errorf("logger: invalid %s output level %q: %w", out.Type, out.Level, err)
+		}
+		lvl = l
+	}
+	// 输出的级别不能比全局的更低
+	if lvl < base {
+		lvl = base
+	}
+	switch out.Type {
+	case "stdout":
+		return zapcore.Lock(os.Stdout), lvl, nil
+	case "stderr":
+		return zapcore.Lock(os.Stderr), lvl, nil
+	case "file":
+		if out.File.Filename == "" {
+			return nil, lvl, errors.New("logger: file output requires filename")
+		}
+		w, err := NewRotateWriter(out.File)
+		if err != nil {
+			return nil, lvl, err
+		}
+		return w, lvl, nil
+	default:
+		return nil, lvl, fmt.Errorf("logger: unknown output type %q", out.Type)
+	}
+}
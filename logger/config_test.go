@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	dir := t.TempDir()
+	l, cleanup, err := NewLogger(Config{
+		Service: "user",
+		Level:   "info",
+		Caller:  true,
+		Outputs: []OutputConfig{
+			{Type: "file", File: RotateConfig{Filename: filepath.Join(dir, "app.log")}},
+			{Type: "file", Level: "error", File: RotateConfig{Filename: filepath.Join(dir, "error.log")}},
+		},
+	})
+	require.NoError(t, err)
+	l.Debug("debug")
+	l.Info("info", String("k", "v"))
+	l.Error("error")
+	cleanup()
+
+	lines := readLines(t, filepath.Join(dir, "app.log"))
+	require.Len(t, lines, 2)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "info", entry["msg"])
+	assert.Equal(t, "user", entry["service"])
+	assert.Equal(t, "v", entry["k"])
+	// 行号指向调用方而不是 ZapLogger
+	assert.Contains(t, entry["caller"], "logger/config_test.go")
+
+	lines = readLines(t, filepath.Join(dir, "error.log"))
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"msg":"error"`)
+}
+
+func TestNewLogger_Console(t *testing.T) {
+	dir := t.TempDir()
+	l, cleanup, err := NewLogger(Config{
+		Encoder: "console",
+		Outputs: []OutputConfig{{Type: "file", File: RotateConfig{Filename: filepath.Join(dir, "app.log")}}},
+	})
+	require.NoError(t, err)
+	l.Warn("hello", Int64("n", 1))
+	cleanup()
+
+	lines := readLines(t, filepath.Join(dir, "app.log"))
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "WARN\thello\t{\"n\": 1}")
+}
+
+func TestConfig_JSON(t *testing.T) {
+	var cfg Config
+	require.NoError(t, json.Unmarshal([]byte(`{"outputs":[{"type":"file","file":{"filename":"app.log","interval":"24h","max_age":"168h"}}]}`), &cfg))
+	assert.Equal(t, RotateConfig{Filename: "app.log", Interval: "24h", MaxAge: "168h"}, cfg.Outputs[0].File)
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "level", cfg: Config{Level: "verbose"}},
+		{name: "encoder", cfg: Config{Encoder: "xml"}},
+		{name: "output", cfg: Config{Outputs: []OutputConfig{{Type: "kafka"}}}},
+		{name: "output level", cfg: Config{Outputs: []OutputConfig{{Type: "stdout", Level: "x"}}}},
+		{name: "filename", cfg: Config{Outputs: []OutputConfig{{Type: "file"}}}},
+		{name: "interval", cfg: Config{Outputs: []OutputConfig{{Type: "file", File: RotateConfig{Filename: "app.log", Interval: "1d"}}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := NewLogger(tc.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func readLines(t *testing.T, name string) []string {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
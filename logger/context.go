@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxFieldsKeyType struct{}
+
+var ctxFieldsKey = ctxFieldsKeyType{}
+
+// ContextWithFields 把请求级别的字段放到 ctx 里，
+// 之后通过 WithContext 或者 Ctx 拿到的 Logger 都会带上这些字段
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	old, _ := ctx.Value(ctxFieldsKey).([]Field)
+	merged := make([]Field, 0, len(old)+len(fields))
+	merged = append(merged, old...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, ctxFieldsKey, merged)
+}
+
+// FieldsFromContext 从 ctx 里取出 trace_id、span_id 以及请求级别的字段
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	stored, _ := ctx.Value(ctxFieldsKey).([]Field)
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return stored
+	}
+	fields := make([]Field, 0, len(stored)+2)
+	fields = append(fields,
+		String("trace_id", sc.TraceID().String()),
+		String("span_id", sc.SpanID().String()))
+	return append(fields, stored...)
+}
+
+// Ctx 返回一个带上 ctx 里链路信息和请求字段的 Logger
+// l 实现了 LoggerV2 就用它自己的 WithContext，否则退化成 With
+func Ctx(ctx context.Context, l Logger) Logger {
+	if lv2, ok := l.(LoggerV2); ok {
+		return lv2.WithContext(ctx)
+	}
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
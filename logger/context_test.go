@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLogger_WithContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewZapLogger(zap.New(core))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = ContextWithFields(ctx, String("uid", "123"))
+	ctx = ContextWithFields(ctx, String("biz", "article"))
+
+	Ctx(ctx, l).Info("hello", Int64("cost", 10))
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", fields["span_id"])
+	assert.Equal(t, "123", fields["uid"])
+	assert.Equal(t, "article", fields["biz"])
+	assert.Equal(t, int64(10), fields["cost"])
+}
+
+func TestCtx_WithoutTrace(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewZapLogger(zap.New(core))
+	Ctx(context.Background(), l).Info("hello")
+	require.Equal(t, 1, logs.Len())
+	assert.Empty(t, logs.All()[0].Context)
+
+	// 没有实现 LoggerV2 的 Logger 也能用
+	var nop Logger = &NopLogger{}
+	assert.Equal(t, nop, Ctx(context.Background(), nop))
+}
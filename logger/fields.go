@@ -1,38 +1,106 @@
 package logger
 
-import "time"
+import (
+	"math"
+	"time"
+)
+
+// FieldType 字段的类型，决定值放在 Field 的哪个位置
+type FieldType uint8
+
+const (
+	// AnyType 值放在 Value 里面，直接写 Field{Key: k, Value: v} 就是这个类型
+	AnyType FieldType = iota
+	StringType
+	Int64Type
+	Int32Type
+	Float64Type
+	BoolType
+	DurationType
+	ErrorType
+	BytesType
+)
+
+// Field 日志字段
+// 常用的类型用 String、Int64 这些构造函数创建，值放在对应的位置，不需要装箱成 any，
+// 输出的时候也能直接对应到 zap 的强类型字段，避免反射。
+// 注意构造函数创建的字段 Value 是 nil，比如 String 的值在 String 里面，Int64 的值在 Integer 里面，
+// 以前直接读 Value 的代码需要改成调用 Any
+type Field struct {
+	Key  string
+	Type FieldType
+	// Int64Type、Int32Type、BoolType、DurationType 的值，以及 Float64Type 的二进制
+	Integer int64
+	// StringType 的值
+	String string
+	// AnyType、ErrorType、BytesType 的值
+	Value any
+}
+
+// Any 按照类型把值还原出来，会装箱，只适合不在乎性能的地方使用
+func (f Field) Any() any {
+	switch f.Type {
+	case StringType:
+		return f.String
+	case Int64Type:
+		return f.Integer
+	case Int32Type:
+		return int32(f.Integer)
+	case Float64Type:
+		return math.Float64frombits(uint64(f.Integer))
+	case BoolType:
+		return f.Integer == 1
+	case DurationType:
+		return time.Duration(f.Integer)
+	default:
+		return f.Value
+	}
+}
 
 func String(key string, value string) Field {
 	return Field{
-		Key:   key,
-		Value: value,
+		Key:    key,
+		Type:   StringType,
+		String: value,
 	}
 }
 
 func Int32(key string, value int32) Field {
 	return Field{
-		Key:   key,
-		Value: value,
+		Key:     key,
+		Type:    Int32Type,
+		Integer: int64(value),
 	}
 }
 func Int64(key string, val int64) Field {
 	return Field{
-		Key:   key,
-		Value: val,
+		Key:     key,
+		Type:    Int64Type,
+		Integer: val,
 	}
 }
 
 func Error(err error) Field {
+	return NamedError("error", err)
+}
+
+func NamedError(key string, err error) Field {
 	return Field{
-		Key:   "error",
+		Key:   key,
+		Type:  ErrorType,
 		Value: err,
 	}
 }
 
 func Bool(key string, val bool) Field {
+	var i int64
+	if val {
+		i = 1
+	}
 	return Field{
-		Key:   key,
-		Value: val,
+		Key:     key,
+		Type:    BoolType,
+		Integer: i,
 	}
 
 }
@@ -40,18 +108,28 @@ func Bool(key string, val bool) Field {
 func Bytes(key string, val []byte) Field {
 	return Field{
 		Key:   key,
+		Type:  BytesType,
 		Value: val,
 	}
 }
 
 func Float64(key string, val float64) Field {
 	return Field{
-		Key:   key,
-		Value: val,
+		Key:     key,
+		Type:    Float64Type,
+		Integer: int64(math.Float64bits(val)),
 	}
 }
 
 func Duration(key string, val time.Duration) Field {
+	return Field{
+		Key:     key,
+		Type:    DurationType,
+		Integer: int64(val),
+	}
+}
+
+func Any(key string, val any) Field {
 	return Field{
 		Key:   key,
 		Value: val,
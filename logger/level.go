@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultLevels 默认的日志级别注册中心，OnceLogger 使用的就是它
+var DefaultLevels = NewLevelRegistry(zapcore.DebugLevel)
+
+// LevelRegistry 管理根级别和各个模块的日志级别，运行时可以修改
+// module 为空字符串代表根级别，没有单独设置过级别的模块跟随根级别
+type LevelRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*levelEntry
+}
+
+type levelEntry struct {
+	level zap.AtomicLevel
+	// false 代表没有单独设置过，跟随根级别
+	explicit bool
+	// 临时覆盖的定时器，到期之后恢复成 prev
+	timer        *time.Timer
+	prevLevel    zapcore.Level
+	prevExplicit bool
+}
+
+// NewLevelRegistry 创建级别注册中心，root 是根级别
+func NewLevelRegistry(root zapcore.Level) *LevelRegistry {
+	return &LevelRegistry{
+		entries: map[string]*levelEntry{
+			"": {level: zap.NewAtomicLevelAt(root), explicit: true},
+		},
+	}
+}
+
+// Level 返回模块的级别，不存在就创建一个跟随根级别的，给构造 Logger 用
+// 返回的 AtomicLevel 会随着 SetLevel 实时变化，可以直接用来构造 zapcore.Core
+func (r *LevelRegistry) Level(module string) zap.AtomicLevel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.entry(module).level
+}
+
+// Lookup 返回模块当前的级别，不存在的模块返回根级别，不会创建新的模块
+// 只是查看级别的时候用它，避免随便传进来的 module 一直留在注册中心里
+func (r *LevelRegistry) Lookup(module string) zapcore.Level {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[module]; ok {
+		return e.level.Level()
+	}
+	return r.entries[""].level.Level()
+}
+
+// SetLevel 设置模块的级别，会取消还没到期的临时覆盖
+func (r *LevelRegistry) SetLevel(module string, lvl zapcore.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(module)
+	r.stopTimer(e)
+	r.apply(module, e, lvl, true)
+}
+
+// SetLevelWithTTL 临时设置模块的级别，ttl 之后自动恢复成设置之前的级别
+// 在到期之前再次临时设置会刷新 ttl，最终还是恢复成第一次临时设置之前的级别
+func (r *LevelRegistry) SetLevelWithTTL(module string, lvl zapcore.Level, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(module)
+	if e.timer == nil {
+		e.prevLevel = e.level.Level()
+		e.prevExplicit = e.explicit
+	} else {
+		e.timer.Stop()
+	}
+	r.apply(module, e, lvl, true)
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// 已经被别的设置覆盖了
+		if e.timer != timer {
+			return
+		}
+		e.timer = nil
+		lvl := e.prevLevel
+		if !e.prevExplicit {
+			lvl = r.entries[""].level.Level()
+		}
+		r.apply(module, e, lvl, e.prevExplicit)
+	})
+	e.timer = timer
+}
+
+// ResetLevel 取消模块单独设置的级别，重新跟随根级别
+// 对根级别调用没有效果
+func (r *LevelRegistry) ResetLevel(module string) {
+	if module == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[module]
+	if !ok {
+		return
+	}
+	r.stopTimer(e)
+	r.apply(module, e, r.entries[""].level.Level(), false)
+}
+
+// Levels 返回所有模块当前的级别
+func (r *LevelRegistry) Levels() map[string]zapcore.Level {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make(map[string]zapcore.Level, len(r.entries))
+	for module, e := range r.entries {
+		res[module] = e.level.Level()
+	}
+	return res
+}
+
+// NewZapLogger 创建一个级别由 module 控制的 Logger
+// l 本身的级别需要足够低（比如 Debug），否则调低模块级别也不会输出
+func (r *LevelRegistry) NewZapLogger(l *zap.Logger, module string) Logger {
+	lvl := r.Level(module)
+	if module != "" {
+		l = l.Named(module)
+	}
+	return NewZapLogger(l.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &levelCore{Core: c, level: lvl}
+	})))
+}
+
+func (r *LevelRegistry) entry(module string) *levelEntry {
+	e, ok := r.entries[module]
+	if !ok {
+		e = &levelEntry{level: zap.NewAtomicLevelAt(r.entries[""].level.Level())}
+		r.entries[module] = e
+	}
+	return e
+}
+
+func (r *LevelRegistry) stopTimer(e *levelEntry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// apply 修改级别，修改的是根级别的话同步给所有跟随根级别的模块
+func (r *LevelRegistry) apply(module string, e *levelEntry, lvl zapcore.Level, explicit bool) {
+	e.level.SetLevel(lvl)
+	e.explicit = explicit
+	if module != "" {
+		return
+	}
+	e.explicit = true
+	for _, other := range r.entries {
+		if !other.explicit {
+			other.level.SetLevel(lvl)
+		}
+	}
+}
+
+// parseLevel 解析 debug、info 之类的级别
+func parseLevel(text string) (zapcore.Level, error) {
+	var lvl zapcore.Level
+	// zap 会把空字符串当成 info，这里不允许
+	if text == "" {
+		return lvl, errors.New("empty level")
+	}
+	err := lvl.UnmarshalText([]byte(text))
+	return lvl, err
+}
+
+// levelCore 在原有 Core 的基础上再加一层可以动态调整的级别过滤
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl) && c.Core.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
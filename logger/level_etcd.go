@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ErrWatchClosed ctx 没有结束，etcd 的监听就被关闭了，比如 etcd 的客户端被关闭了
+var ErrWatchClosed = errors.New("logger: etcd watch channel closed")
+
+// EtcdClient 监听日志级别需要用到的 etcd 能力，*clientv3.Client 就实现了这个接口
+type EtcdClient interface {
+	clientv3.KV
+	clientv3.Watcher
+}
+
+// etcdLevel etcd 里保存的级别，也可以直接保存 debug 这种字符串
+type etcdLevel struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+// WatchEtcd 从 etcd 的 prefix 下加载日志级别并持续监听变化，阻塞直到 ctx 结束
+// key 去掉 prefix 之后就是模块名，key 等于 prefix 代表根级别。
+// value 可以是 debug 这样的级别，也可以是 {"level":"debug","ttl":"10m"}。
+// key 被删除之后模块重新跟随根级别，解析不了的 value 会被忽略。
+// ctx 结束的时候返回 ctx.Err()，监听被关闭的时候返回 ErrWatchClosed
+func (r *LevelRegistry) WatchEtcd(ctx context.Context, client EtcdClient, prefix string) error {
+	resp, err := client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		r.applyEtcd(strings.TrimPrefix(string(kv.Key), prefix), kv.Value)
+	}
+	// 从 Get 的版本之后开始监听，避免漏掉中间的修改
+	ch := client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range ch {
+		if err = wresp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range wresp.Events {
+			module := strings.TrimPrefix(string(ev.Kv.Key), prefix)
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				r.applyEtcd(module, ev.Kv.Value)
+			case clientv3.EventTypeDelete:
+				r.ResetLevel(module)
+			}
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWatchClosed
+}
+
+func (r *LevelRegistry) applyEtcd(module string, val []byte) {
+	cfg := etcdLevel{Level: strings.TrimSpace(string(val))}
+	if strings.HasPrefix(cfg.Level, "{") {
+		if err := json.Unmarshal(val, &cfg); err != nil {
+			return
+		}
+	}
+	lvl, err := parseLevel(cfg.Level)
+	if err != nil {
+		return
+	}
+	if cfg.TTL == "" {
+		r.SetLevel(module, lvl)
+		return
+	}
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil || ttl <= 0 {
+		return
+	}
+	r.SetLevelWithTTL(module, lvl, ttl)
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LevelHandler 查看和修改日志级别的 HTTP 接口
+//
+//	GET    ?module=xxx 查看某个模块的级别，不传 module 返回所有模块
+//	PUT    {"module": "saramax", "level": "debug", "ttl": "10m"} 修改级别，ttl 可选
+//	DELETE ?module=xxx 取消模块单独设置的级别，重新跟随根级别
+//
+// module 为空代表根级别。在 gin 里可以用 gin.WrapH 挂载
+type LevelHandler struct {
+	levels *LevelRegistry
+}
+
+func NewLevelHandler(levels *LevelRegistry) *LevelHandler {
+	return &LevelHandler{levels: levels}
+}
+
+type levelReq struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+	// 临时修改的时长，例如 10m，为空代表永久修改
+	TTL string `json:"ttl"`
+}
+
+type levelResp struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Has("module") {
+			module := r.URL.Query().Get("module")
+			h.writeJSON(w, http.StatusOK, levelResp{Module: module, Level: h.levels.Lookup(module).String()})
+			return
+		}
+		levels := h.levels.Levels()
+		res := make(map[string]string, len(levels))
+		for module, lvl := range levels {
+			res[module] = lvl.String()
+		}
+		h.writeJSON(w, http.StatusOK, res)
+	case http.MethodPut, http.MethodPost:
+		var req levelReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, fmt.Errorf("decode request: %w", err))
+			return
+		}
+		lvl, err := parseLevel(req.Level)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		if req.TTL == "" {
+			h.levels.SetLevel(req.Module, lvl)
+		} else {
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				h.writeError(w, fmt.Errorf("invalid ttl: %s", req.TTL))
+				return
+			}
+			h.levels.SetLevelWithTTL(req.Module, lvl, ttl)
+		}
+		h.writeJSON(w, http.StatusOK, levelResp{Module: req.Module, Level: lvl.String()})
+	case http.MethodDelete:
+		module := r.URL.Query().Get("module")
+		h.levels.ResetLevel(module)
+		h.writeJSON(w, http.StatusOK, levelResp{Module: module, Level: h.levels.Lookup(module).String()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *LevelHandler) writeError(w http.ResponseWriter, err error) {
+	h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+func (h *LevelHandler) writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
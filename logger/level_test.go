@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLevelRegistry(t *testing.T) {
+	r := NewLevelRegistry(zapcore.InfoLevel)
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := r.NewZapLogger(zap.New(core), "saramax")
+
+	l.Debug("dropped")
+	l.Info("kept")
+	require.Equal(t, 1, logs.Len())
+
+	r.SetLevel("saramax", zapcore.DebugLevel)
+	l.Debug("kept")
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "saramax", logs.All()[1].LoggerName)
+
+	// 单独设置过的模块不跟随根级别
+	r.SetLevel("", zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.DebugLevel, r.Level("saramax").Level())
+	assert.Equal(t, zapcore.ErrorLevel, r.Level("grpc").Level())
+
+	r.ResetLevel("saramax")
+	assert.Equal(t, zapcore.ErrorLevel, r.Level("saramax").Level())
+	l.Warn("dropped")
+	assert.Equal(t, 2, logs.Len())
+}
+
+func TestLevelRegistry_TTL(t *testing.T) {
+	r := NewLevelRegistry(zapcore.InfoLevel)
+	r.SetLevel("saramax", zapcore.WarnLevel)
+	r.SetLevelWithTTL("saramax", zapcore.DebugLevel, time.Millisecond*50)
+	// 再次临时设置，最终还是恢复成 warn
+	r.SetLevelWithTTL("saramax", zapcore.ErrorLevel, time.Millisecond*50)
+	assert.Equal(t, zapcore.ErrorLevel, r.Level("saramax").Level())
+	assert.Eventually(t, func() bool {
+		return r.Level("saramax").Level() == zapcore.WarnLevel
+	}, time.Second, time.Millisecond*10)
+
+	// 跟随根级别的模块恢复之后继续跟随根级别
+	r.SetLevelWithTTL("grpc", zapcore.DebugLevel, time.Millisecond*50)
+	r.SetLevel("", zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.DebugLevel, r.Level("grpc").Level())
+	assert.Eventually(t, func() bool {
+		return r.Level("grpc").Level() == zapcore.ErrorLevel
+	}, time.Second, time.Millisecond*10)
+
+	// 永久设置会取消临时设置
+	r.SetLevelWithTTL("redis", zapcore.DebugLevel, time.Millisecond*20)
+	r.SetLevel("redis", zapcore.WarnLevel)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, zapcore.WarnLevel, r.Level("redis").Level())
+}
+
+func TestLevelHandler(t *testing.T) {
+	r := NewLevelRegistry(zapcore.InfoLevel)
+	h := NewLevelHandler(r)
+
+	req := httptest.NewRequest(http.MethodPut, "/",
+		strings.NewReader(`{"module":"saramax","level":"debug"}`))
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, zapcore.DebugLevel, r.Level("saramax").Level())
+
+	req = httptest.NewRequest(http.MethodGet, "/?module=saramax", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.JSONEq(t, `{"module":"saramax","level":"debug"}`, resp.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.JSONEq(t, `{"":"info","saramax":"debug"}`, resp.Body.String())
+
+	req = httptest.NewRequest(http.MethodPut, "/",
+		strings.NewReader(`{"module":"grpc","level":"error","ttl":"20ms"}`))
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Eventually(t, func() bool {
+		return r.Level("grpc").Level() == zapcore.InfoLevel
+	}, time.Second, time.Millisecond*10)
+
+	// 查看和取消不存在的模块不会把它加到注册中心里
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req = httptest.NewRequest(method, "/?module=unknown", nil)
+		resp = httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		assert.JSONEq(t, `{"module":"unknown","level":"info"}`, resp.Body.String())
+	}
+	assert.NotContains(t, r.Levels(), "unknown")
+
+	req = httptest.NewRequest(http.MethodDelete, "/?module=saramax", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, zapcore.InfoLevel, r.Level("saramax").Level())
+
+	for _, body := range []string{`{"level":"verbose"}`, `{"level":""}`, `{"level":"debug","ttl":"abc"}`, `xxx`} {
+		req = httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		resp = httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+	}
+}
+
+type fakeEtcd struct {
+	clientv3.KV
+	clientv3.Watcher
+	kvs []*mvccpb.KeyValue
+	ch  chan clientv3.WatchResponse
+}
+
+func (f *fakeEtcd) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return &clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: 10},
+		Kvs:    f.kvs,
+	}, nil
+}
+
+func (f *fakeEtcd) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	return f.ch
+}
+
+func TestLevelRegistry_WatchEtcd(t *testing.T) {
+	r := NewLevelRegistry(zapcore.InfoLevel)
+	client := &fakeEtcd{
+		kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/log/level/"), Value: []byte("warn")},
+			{Key: []byte("/log/level/saramax"), Value: []byte("debug")},
+			{Key: []byte("/log/level/bad"), Value: []byte("verbose")},
+		},
+		ch: make(chan clientv3.WatchResponse, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchEtcd(ctx, client, "/log/level/")
+	}()
+	assert.Eventually(t, func() bool {
+		return r.Level("saramax").Level() == zapcore.DebugLevel
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, zapcore.WarnLevel, r.Level("").Level())
+	assert.Equal(t, zapcore.WarnLevel, r.Level("bad").Level())
+
+	client.ch <- clientv3.WatchResponse{Events: []*clientv3.Event{
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{
+			Key: []byte("/log/level/grpc"), Value: []byte(`{"level":"error","ttl":"1h"}`)}},
+		{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: []byte("/log/level/saramax")}},
+	}}
+	assert.Eventually(t, func() bool {
+		return r.Level("grpc").Level() == zapcore.ErrorLevel &&
+			r.Level("saramax").Level() == zapcore.WarnLevel
+	}, time.Second, time.Millisecond*10)
+
+	cancel()
+	close(client.ch)
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestLevelRegistry_WatchEtcdClosed(t *testing.T) {
+	r := NewLevelRegistry(zapcore.InfoLevel)
+	client := &fakeEtcd{ch: make(chan clientv3.WatchResponse)}
+	// ctx 没有结束，监听被关闭了，比如 etcd 的客户端被关闭了
+	close(client.ch)
+	assert.ErrorIs(t, r.WatchEtcd(context.Background(), client, "/log/level/"), ErrWatchClosed)
+}
@@ -0,0 +1,237 @@
+// Package loggertest 提供测试用的 Logger，把日志记录在内存里面，方便断言
+package loggertest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Kirby980/go-pkg/logger"
+)
+
+// Entry 一条日志
+type Entry struct {
+	Level   logger.Level
+	Message string
+	// 调用 Debug、Info 这些方法时传入的字段
+	Fields []logger.Field
+	// With 链上带的字段，按照 With 的顺序
+	Context []logger.Field
+}
+
+// AllFields 返回 With 带的字段和调用时传入的字段
+func (e Entry) AllFields() []logger.Field {
+	res := make([]logger.Field, 0, len(e.Context)+len(e.Fields))
+	res = append(res, e.Context...)
+	return append(res, e.Fields...)
+}
+
+// Field 返回 key 对应的字段值，调用时传入的优先于 With 带的
+func (e Entry) Field(key string) (any, bool) {
+	all := e.AllFields()
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Key == key {
+			return all[i].Any(), true
+		}
+	}
+	return nil, false
+}
+
+func (e Entry) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Level.String())
+	sb.WriteString(" ")
+	sb.WriteString(e.Message)
+	for _, f := range e.AllFields() {
+		fmt.Fprintf(&sb, " %s=%v", f.Key, f.Any())
+	}
+	return sb.String()
+}
+
+// Recorder 记录日志的 Logger，可以并发使用
+// With 和 WithContext 派生出来的 Logger 记录到同一个地方
+type Recorder struct {
+	store   *store
+	context []logger.Field
+}
+
+type store struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{store: &store{}}
+}
+
+// With implements logger.Logger.
+func (r *Recorder) With(args ...logger.Field) logger.Logger {
+	if len(args) == 0 {
+		return r
+	}
+	ctxFields := make([]logger.Field, 0, len(r.context)+len(args))
+	ctxFields = append(ctxFields, r.context...)
+	ctxFields = append(ctxFields, args...)
+	return &Recorder{store: r.store, context: ctxFields}
+}
+
+// WithContext implements logger.LoggerV2.
+func (r *Recorder) WithContext(ctx context.Context) logger.Logger {
+	return r.With(logger.FieldsFromContext(ctx)...)
+}
+
+func (r *Recorder) Debug(msg string, args ...logger.Field) {
+	r.record(logger.DebugLevel, msg, args)
+}
+
+func (r *Recorder) Info(msg string, args ...logger.Field) {
+	r.record(logger.InfoLevel, msg, args)
+}
+
+func (r *Recorder) Warn(msg string, args ...logger.Field) {
+	r.record(logger.WarnLevel, msg, args)
+}
+
+func (r *Recorder) Error(msg string, args ...logger.Field) {
+	r.record(logger.ErrorLevel, msg, args)
+}
+
+func (r *Recorder) record(level logger.Level, msg string, args []logger.Field) {
+	e := Entry{
+		Level:   level,
+		Message: msg,
+		Fields:  append([]logger.Field(nil), args...),
+		Context: r.context,
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = append(r.store.entries, e)
+}
+
+// Entries 返回所有记录的日志
+func (r *Recorder) Entries() []Entry {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return append([]Entry(nil), r.store.entries...)
+}
+
+// Len 返回记录的日志条数
+func (r *Recorder) Len() int {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return len(r.store.entries)
+}
+
+// Reset 清空记录的日志，派生出来的 Logger 也会一起清空
+func (r *Recorder) Reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = nil
+}
+
+// Filter 返回满足条件的日志
+func (r *Recorder) Filter(fn func(e Entry) bool) []Entry {
+	var res []Entry
+	for _, e := range r.Entries() {
+		if fn(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// FilterLevel 返回某个级别的日志
+func (r *Recorder) FilterLevel(level logger.Level) []Entry {
+	return r.Filter(func(e Entry) bool {
+		return e.Level == level
+	})
+}
+
+// FilterMessage 返回消息包含 substr 的日志
+func (r *Recorder) FilterMessage(substr string) []Entry {
+	return r.Filter(func(e Entry) bool {
+		return strings.Contains(e.Message, substr)
+	})
+}
+
+// FilterField 返回带有某个字段，并且值相等的日志
+// 按照还原之后的值比较，logger.Int64("n", 1) 和 logger.Field{Key: "n", Value: int64(1)} 是相等的
+func (r *Recorder) FilterField(f logger.Field) []Entry {
+	return r.Filter(func(e Entry) bool {
+		return e.hasFields([]logger.Field{f})
+	})
+}
+
+// TestingT *testing.T 满足这个接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertLogged 断言有一条 level 级别、消息包含 msgSubstring 并且带有 fields 的日志
+// fields 会和 With 带的字段一起比较，只要求包含，不要求完全一致
+func (r *Recorder) AssertLogged(t TestingT, level logger.Level, msgSubstring string, fields ...logger.Field) bool {
+	t.Helper()
+	if len(r.match(level, msgSubstring, fields)) > 0 {
+		return true
+	}
+	t.Errorf("no %s log contains %q with fields %s\nrecorded:\n%s",
+		level, msgSubstring, formatFields(fields), r.dump())
+	return false
+}
+
+// AssertNotLogged 断言没有 level 级别、消息包含 msgSubstring 并且带有 fields 的日志
+func (r *Recorder) AssertNotLogged(t TestingT, level logger.Level, msgSubstring string, fields ...logger.Field) bool {
+	t.Helper()
+	matched := r.match(level, msgSubstring, fields)
+	if len(matched) == 0 {
+		return true
+	}
+	t.Errorf("unexpected %s log contains %q with fields %s: %s",
+		level, msgSubstring, formatFields(fields), matched[0])
+	return false
+}
+
+func (r *Recorder) match(level logger.Level, msgSubstring string, fields []logger.Field) []Entry {
+	return r.Filter(func(e Entry) bool {
+		return e.Level == level && strings.Contains(e.Message, msgSubstring) && e.hasFields(fields)
+	})
+}
+
+func (e Entry) hasFields(fields []logger.Field) bool {
+	for _, f := range fields {
+		found := false
+		for _, ef := range e.AllFields() {
+			if ef.Key == f.Key && reflect.DeepEqual(ef.Any(), f.Any()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Recorder) dump() string {
+	entries := r.Entries()
+	if len(entries) == 0 {
+		return "  (none)"
+	}
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, "  "+e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatFields(fields []logger.Field) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, fmt.Sprintf("%s=%v", f.Key, f.Any()))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
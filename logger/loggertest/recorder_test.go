@@ -0,0 +1,83 @@
+package loggertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Kirby980/go-pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT 记录断言失败的信息
+type fakeT struct {
+	msgs []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.msgs = append(f.msgs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	var l logger.Logger = r
+	errBoom := errors.New("boom")
+	l.With(logger.String("topic", "article")).
+		With(logger.Int64("partition", 1)).
+		Error("消费消息失败", logger.Error(errBoom))
+	l.Info("启动成功")
+
+	r.AssertLogged(t, logger.ErrorLevel, "消费消息",
+		logger.String("topic", "article"), logger.Error(errBoom))
+	r.AssertNotLogged(t, logger.WarnLevel, "消费消息")
+
+	entries := r.FilterLevel(logger.ErrorLevel)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []logger.Field{logger.String("topic", "article"), logger.Int64("partition", 1)}, entries[0].Context)
+	assert.Equal(t, []logger.Field{logger.Error(errBoom)}, entries[0].Fields)
+	val, ok := entries[0].Field("partition")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), val)
+	assert.Len(t, r.FilterMessage("启动"), 1)
+	assert.Len(t, r.FilterField(logger.Int64("partition", 1)), 1)
+
+	ft := &fakeT{}
+	assert.False(t, r.AssertLogged(ft, logger.ErrorLevel, "消费消息", logger.String("topic", "user")))
+	require.Len(t, ft.msgs, 1)
+	assert.Contains(t, ft.msgs[0], "error 消费消息失败 topic=article partition=1 error=boom")
+	assert.False(t, r.AssertNotLogged(ft, logger.InfoLevel, "启动"))
+
+	r.Reset()
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRecorder_WithContext(t *testing.T) {
+	r := NewRecorder()
+	ctx := logger.ContextWithFields(context.Background(), logger.String("biz", "order"))
+	logger.Ctx(ctx, r).Warn("重试")
+	r.AssertLogged(t, logger.WarnLevel, "重试", logger.String("biz", "order"))
+}
+
+func TestRecorder_Concurrent(t *testing.T) {
+	r := NewRecorder()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := r.With(logger.Int64("worker", int64(i)))
+			for j := 0; j < 100; j++ {
+				l.Debug("tick")
+				_ = r.Len()
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, r.Len())
+	assert.Len(t, r.FilterField(logger.Int64("worker", 3)), 100)
+}
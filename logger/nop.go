@@ -1,5 +1,7 @@
 package logger
 
+import "context"
+
 type NopLogger struct{}
 
 // With implements LoggerV1.
@@ -7,6 +9,11 @@ func (n *NopLogger) With(args ...Field) Logger {
 	return n
 }
 
+// WithContext implements LoggerV2.
+func (n *NopLogger) WithContext(ctx context.Context) Logger {
+	return n
+}
+
 func (n *NopLogger) Debug(msg string, fields ...Field) {
 }
 func (n *NopLogger) Info(msg string, fields ...Field) {
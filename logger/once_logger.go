@@ -1,52 +1,29 @@
 package logger
 
 import (
-	"os"
 	"sync"
-
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
-type logger struct {
-	*zap.SugaredLogger
-}
-
-var log *logger
-var once sync.Once
+var (
+	onceL Logger
+	once  sync.Once
+)
 
-func OnceLogger(name string) *logger {
+// OnceLogger 返回进程内唯一的默认 Logger，JSON 格式输出到 stdout
+// name 作为服务名和模块名，级别由 DefaultLevels 控制，运行时可以修改
+// 需要输出到文件或者自定义格式的话用 NewLogger
+func OnceLogger(name string) Logger {
 	once.Do(func() {
-		log = newLogger(true, name)
-	})
-	return log
-
-}
-
-func newLogger(release bool, scope string) *logger {
-	debugPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.DebugLevel
+		// 级别交给 DefaultLevels 过滤，这里放开到 debug
+		l, _, err := NewZap(Config{
+			Service: name,
+			Level:   "debug",
+			Caller:  true,
+		})
+		if err != nil {
+			panic(err)
+		}
+		onceL = DefaultLevels.NewZapLogger(l, name)
 	})
-
-	var encoder zapcore.Encoder
-	if release {
-		encoderConfig := zap.NewProductionEncoderConfig()
-		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		encoder = zapcore.NewJSONEncoder(encoderConfig)
-
-	} else {
-		encoderConfig := zap.NewDevelopmentEncoderConfig()
-		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		encoder = zapcore.NewConsoleEncoder(encoderConfig)
-	}
-
-	consoleStdout := zapcore.Lock(os.Stdout)
-
-	zapCores := make([]zapcore.Core, 0)
-
-	zapCores = append(zapCores, zapcore.NewCore(encoder, consoleStdout, debugPriority))
-	coreTree := zapcore.NewTee(zapCores...)
-
-	sugar := zap.New(coreTree).Sugar()
-	return &logger{sugar}
+	return onceL
 }
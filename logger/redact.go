@@ -0,0 +1,365 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// MaskFunc 脱敏的方式，输入原始的值，返回脱敏之后的值
+type MaskFunc func(s string) string
+
+// MaskFull 全部替换成 ******
+func MaskFull(s string) string {
+	return "******"
+}
+
+// MaskPartial 保留前面三分之一和后面四分之一，中间替换成 *
+// 例如 13812345678 会变成 138******78
+func MaskPartial(s string) string {
+	runes := []rune(s)
+	n := len(runes)
+	if n < 3 {
+		return strings.Repeat("*", n)
+	}
+	head, tail := n/3, n/4
+	return string(runes[:head]) + strings.Repeat("*", n-head-tail) + string(runes[n-tail:])
+}
+
+// MaskHash 替换成 sha256 的前 16 位，同一个值脱敏之后还是一样的，方便排查问题
+func MaskHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// 结构体标签 log:"xxx" 的取值
+var tagMasks = map[string]MaskFunc{
+	"mask":    MaskFull,
+	"full":    MaskFull,
+	"partial": MaskPartial,
+	"hash":    MaskHash,
+}
+
+// Redactor 日志脱敏，支持三种规则：
+//   - 按字段名，例如 password、token，不区分大小写
+//   - 按正则匹配字符串的值，例如手机号、身份证号，只替换匹配到的部分
+//   - 按结构体标签，log:"mask"、log:"partial"、log:"hash"，log:"-" 代表不输出这个字段
+//
+// Redactor 创建之后只读，可以并发使用
+type Redactor struct {
+	keys     map[string]MaskFunc
+	patterns []patternRule
+	// 按字段名匹配 JSON 片段，用来处理被截断了的 JSON
+	keyPattern *regexp.Regexp
+}
+
+type patternRule struct {
+	re   *regexp.Regexp
+	mask MaskFunc
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{
+		keys: make(map[string]MaskFunc),
+	}
+}
+
+// Keys 按照字段名脱敏
+func (r *Redactor) Keys(mask MaskFunc, keys ...string) *Redactor {
+	for _, k := range keys {
+		r.keys[strings.ToLower(k)] = mask
+	}
+	names := make([]string, 0, len(r.keys))
+	for k := range r.keys {
+		names = append(names, regexp.QuoteMeta(k))
+	}
+	r.keyPattern = regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"(\s*:\s*)"((?:[^"\\]|\\.)*)"?`)
+	return r
+}
+
+// Pattern 字符串的值里面匹配到 re 的部分会被脱敏
+func (r *Redactor) Pattern(re *regexp.Regexp, mask MaskFunc) *Redactor {
+	r.patterns = append(r.patterns, patternRule{re: re, mask: mask})
+	return r
+}
+
+// Fields 对日志字段脱敏，返回新的切片，不会修改传入的字段
+func (r *Redactor) Fields(fields []Field) []Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	res := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		if mask, ok := r.keys[strings.ToLower(f.Key)]; ok {
+			if s, ok := r.maskAny(mask, f.Any()).(string); ok {
+				res = append(res, String(f.Key, s))
+			} else {
+				res = append(res, Field{Key: f.Key})
+			}
+			continue
+		}
+		switch f.Type {
+		case StringType:
+			f.String = r.String(f.String)
+		case AnyType:
+			f.Value = r.Value(f.Value)
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+// String 对字符串按正则脱敏
+func (r *Redactor) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, p.mask)
+	}
+	return s
+}
+
+// JSON 对 JSON 文本脱敏，例如请求体和响应体
+// 解析失败（比如被截断了）的时候退化成按文本处理，"password": "xxx" 这种形式的字段仍然会被脱敏
+// 数字按照原样保留，不会因为转换成 float64 丢失精度，例如很大的 ID
+func (r *Redactor) JSON(s string) string {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return r.text(s)
+	}
+	// 后面还有别的内容，不是一个完整的 JSON
+	if _, err := dec.Token(); err != io.EOF {
+		return r.text(s)
+	}
+	data, err := json.Marshal(r.Value(v))
+	if err != nil {
+		return r.text(s)
+	}
+	return string(data)
+}
+
+// URL 对 URL 的查询参数按字段名脱敏，其余部分按正则脱敏
+func (r *Redactor) URL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.RawQuery == "" {
+		return r.String(s)
+	}
+	query := u.Query()
+	for k, vals := range query {
+		mask, ok := r.keys[strings.ToLower(k)]
+		for i, v := range vals {
+			if ok {
+				vals[i] = mask(v)
+			} else {
+				vals[i] = r.String(v)
+			}
+		}
+	}
+	// * 在查询参数里面是合法的，不转义方便阅读
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "%2A", "*")
+	return r.String(u.String())
+}
+
+// Value 对任意的值脱敏
+// 结构体、map、切片会被转换成 map[string]any 和 []any，结构体的字段名跟 JSON 保持一致
+func (r *Redactor) Value(v any) any {
+	if v == nil {
+		return nil
+	}
+	if s, ok := v.(string); ok {
+		return r.String(s)
+	}
+	return r.value(reflect.ValueOf(v), 0)
+}
+
+// 避免循环引用导致栈溢出
+const maxRedactDepth = 16
+
+func (r *Redactor) value(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return v.Interface()
+	}
+	switch val := v.Interface().(type) {
+	case json.Number:
+		// 数字也可能匹配正则，例如手机号，脱敏之后只能是字符串
+		if masked := r.String(val.String()); masked != val.String() {
+			return masked
+		}
+		return val
+	case error, json.Marshaler:
+		// 比如 time.Time，交给它自己序列化
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return r.value(v.Elem(), depth+1)
+	case reflect.Struct:
+		return r.structValue(v, depth)
+	case reflect.String:
+		return r.String(v.String())
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		res := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			if mask, ok := r.keys[strings.ToLower(k)]; ok {
+				res[k] = r.maskAny(mask, iter.Value())
+				continue
+			}
+			res[k] = r.value(iter.Value(), depth+1)
+		}
+		return res
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		res := make([]any, v.Len())
+		for i := range res {
+			res[i] = r.value(v.Index(i), depth+1)
+		}
+		return res
+	default:
+		return v.Interface()
+	}
+}
+
+// structValue 把结构体转换成 map，只处理导出的字段
+func (r *Redactor) structValue(v reflect.Value, depth int) map[string]any {
+	t := v.Type()
+	res := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			n, _, _ := strings.Cut(tag, ",")
+			if n == "-" {
+				continue
+			}
+			if n != "" {
+				name = n
+			}
+		}
+		tag := sf.Tag.Get("log")
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if mask, ok := tagMasks[tag]; ok {
+			res[name] = r.maskAny(mask, fv)
+			continue
+		}
+		if mask, ok := r.keys[strings.ToLower(name)]; ok {
+			res[name] = r.maskAny(mask, fv)
+			continue
+		}
+		res[name] = r.value(fv, depth+1)
+	}
+	return res
+}
+
+// maskAny 不是字符串的值先转换成字符串再脱敏，nil 和零值指针保持不变
+func (r *Redactor) maskAny(mask MaskFunc, v any) any {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+	for rv.IsValid() && (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return mask(rv.String())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return mask(string(rv.Bytes()))
+		}
+	}
+	return mask(fmt.Sprint(rv.Interface()))
+}
+
+// text 按文本处理，先按字段名匹配 "key": "value"，再按正则
+func (r *Redactor) text(s string) string {
+	if r.keyPattern != nil {
+		matches := r.keyPattern.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) > 0 {
+			var sb strings.Builder
+			last := 0
+			for _, m := range matches {
+				// m[2]:m[3] 是字段名，m[6]:m[7] 是值
+				mask := r.keys[strings.ToLower(s[m[2]:m[3]])]
+				sb.WriteString(s[last:m[6]])
+				sb.WriteString(mask(s[m[6]:m[7]]))
+				last = m[7]
+			}
+			sb.WriteString(s[last:])
+			s = sb.String()
+		}
+	}
+	return r.String(s)
+}
+
+// RedactLogger 装饰器模式
+// 输出之前对所有的字段脱敏，包括 With 带上的字段
+type RedactLogger struct {
+	l Logger
+	r *Redactor
+}
+
+func NewRedactLogger(l Logger, r *Redactor) Logger {
+	return &RedactLogger{l: l, r: r}
+}
+
+// With implements Logger.
+func (rl *RedactLogger) With(args ...Field) Logger {
+	return &RedactLogger{l: rl.l.With(rl.r.Fields(args)...), r: rl.r}
+}
+
+// WithContext implements LoggerV2.
+func (rl *RedactLogger) WithContext(ctx context.Context) Logger {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return rl
+	}
+	return rl.With(fields...)
+}
+
+func (rl *RedactLogger) Debug(msg string, args ...Field) {
+	rl.l.Debug(rl.r.String(msg), rl.r.Fields(args)...)
+}
+
+func (rl *RedactLogger) Info(msg string, args ...Field) {
+	rl.l.Info(rl.r.String(msg), rl.r.Fields(args)...)
+}
+
+func (rl *RedactLogger) Warn(msg string, args ...Field) {
+	rl.l.Warn(rl.r.String(msg), rl.r.Fields(args)...)
+}
+
+func (rl *RedactLogger) Error(msg string, args ...Field) {
+	rl.l.Error(rl.r.String(msg), rl.r.Fields(args)...)
+}
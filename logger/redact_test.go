@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestRedactor() *Redactor {
+	return NewRedactor().
+		Keys(MaskFull, "password", "Authorization").
+		Keys(MaskHash, "token").
+		Pattern(regexp.MustCompile(`1[3-9]\d{9}`), MaskPartial)
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "******", MaskFull("abc"))
+	assert.Equal(t, "138******78", MaskPartial("13812345678"))
+	assert.Equal(t, "**", MaskPartial("ab"))
+	assert.Equal(t, "张**", MaskPartial("张三丰"))
+	assert.Equal(t, MaskHash("abc"), MaskHash("abc"))
+	assert.Len(t, MaskHash("abc"), len("sha256:")+16)
+}
+
+type signupReq struct {
+	Email    string `json:"email" log:"partial"`
+	Password string `json:"password"`
+	IDCard   string `json:"id_card" log:"hash"`
+	Secret   string `log:"-"`
+	Phone    *string
+	Remark   string `json:"remark"`
+	Tags     []string
+	Created  time.Time `json:"created"`
+	inner    string
+}
+
+func TestRedactor_Value(t *testing.T) {
+	r := newTestRedactor()
+	phone := "13812345678"
+	created := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	res := r.Value(&signupReq{
+		Email:    "abc@qq.com",
+		Password: "123456",
+		IDCard:   "110101199001011234",
+		Secret:   "secret",
+		Phone:    &phone,
+		Remark:   "手机号 13912345678 联系我",
+		Tags:     []string{"a"},
+		Created:  created,
+		inner:    "inner",
+	})
+	assert.Equal(t, map[string]any{
+		"email":    "abc*****om",
+		"password": "******",
+		"id_card":  MaskHash("110101199001011234"),
+		"Phone":    "138******78",
+		"remark":   "手机号 139******78 联系我",
+		"Tags":     []any{"a"},
+		"created":  created,
+	}, res)
+
+	assert.Equal(t, map[string]any{
+		"token": MaskHash("t"),
+		"list":  []any{map[string]any{"PASSWORD": "******"}},
+		"n":     1,
+	}, r.Value(map[string]any{
+		"token": "t",
+		"list":  []map[string]string{{"PASSWORD": "x"}},
+		"n":     1,
+	}))
+}
+
+func TestRedactor_JSON(t *testing.T) {
+	r := newTestRedactor()
+	assert.JSONEq(t, `{"user":{"password":"******","phone":"138******78"},"n":1}`,
+		r.JSON(`{"user":{"password":"123","phone":"13812345678"},"n":1}`))
+	// 截断了的 JSON
+	assert.Equal(t, `{"phone":"138******78","password": "******`,
+		r.JSON(`{"phone":"13812345678","password": "12345`))
+	assert.Equal(t, "plain 138******78", r.JSON("plain 13812345678"))
+	// 很大的数字不会丢失精度
+	assert.Equal(t, `{"id":1234567890123456789,"password":"******","price":0.1}`,
+		r.JSON(`{"id":1234567890123456789,"password":"123","price":0.1}`))
+	// 数字形式的手机号也会被脱敏
+	assert.Equal(t, `{"phone":"138******78"}`, r.JSON(`{"phone":13812345678}`))
+}
+
+func TestRedactor_URL(t *testing.T) {
+	r := newTestRedactor()
+	assert.Equal(t, "/login?password=******&user=138******78",
+		r.URL("/login?user=13812345678&password=123"))
+	assert.Equal(t, "/users/138******78", r.URL("/users/13812345678"))
+}
+
+func TestRedactLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewRedactLogger(NewZapLogger(zap.New(core)), newTestRedactor())
+	l.With(String("authorization", "Bearer xxx")).
+		Info("用户 13812345678 登录", String("password", "123"), Int64("uid", 1))
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "用户 138******78 登录", entries[0].Message)
+	assert.Equal(t, map[string]any{
+		"authorization": "******",
+		"password":      "******",
+		"uid":           int64(1),
+	}, entries[0].ContextMap())
+}
@@ -0,0 +1,358 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 备份文件名里的时间格式，例如 app-2026-10-17T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateConfig 滚动日志文件的配置
+type RotateConfig struct {
+	// 当前写入的文件，备份文件放在同一个目录
+	Filename string `json:"filename" yaml:"filename"`
+	// 单个文件的最大大小，单位 MB，0 代表不按大小切分
+	MaxSize int `json:"max_size" yaml:"max_size"`
+	// 按时间切分的周期，time.ParseDuration 的格式，例如 1h、24h，按本地时间对齐，为空代表不按时间切分
+	Interval string `json:"interval" yaml:"interval"`
+	// 备份文件最长保留多久，例如 168h，为空代表不按时间清理
+	MaxAge string `json:"max_age" yaml:"max_age"`
+	// 最多保留多少个备份文件，0 代表不按数量清理
+	MaxBackups int `json:"max_backups" yaml:"max_backups"`
+	// 备份文件是否用 gzip 压缩
+	Compress bool `json:"compress" yaml:"compress"`
+}
+
+// RotateWriter 按大小和时间切分的日志文件，实现了 io.WriteCloser
+// 切分出来的备份文件会在后台压缩和清理
+type RotateWriter struct {
+	cfg      RotateConfig
+	maxSize  int64
+	interval time.Duration
+	maxAge   time.Duration
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// 下一次按时间切分的时间点
+	nextRotate time.Time
+
+	// 通知后台做压缩和清理
+	millCh chan struct{}
+	done   chan struct{}
+	closed bool
+
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewRotateWriter 打开或者创建日志文件，已有的文件会继续追加
+func NewRotateWriter(cfg RotateConfig) (*RotateWriter, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("rotate: filename is required")
+	}
+	interval, err := parseRotateDuration("interval", cfg.Interval)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := parseRotateDuration("max_age", cfg.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{
+		cfg:      cfg,
+		maxSize:  int64(cfg.MaxSize) * 1024 * 1024,
+		interval: interval,
+		maxAge:   maxAge,
+		millCh:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+	go w.millLoop()
+	// 启动的时候顺便清理一下之前留下来的备份
+	w.mill()
+	return w, nil
+}
+
+// parseRotateDuration 空字符串代表不启用
+func parseRotateDuration(name, val string) (time.Duration, error) {
+	if val == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("rotate: invalid %s %q", name, val)
+	}
+	return d, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.openNew(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Sync 把文件内容刷到磁盘
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Rotate 立刻切分当前文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// Close 关闭文件，并且等待后台的压缩和清理结束
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	close(w.millCh)
+	<-w.done
+	return err
+}
+
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	// 空文件写一条超过大小的日志，也不切分
+	if w.maxSize > 0 && w.size > 0 && w.size+n > w.maxSize {
+		return true
+	}
+	return !w.nextRotate.IsZero() && !w.now().Before(w.nextRotate)
+}
+
+func (w *RotateWriter) openExisting() error {
+	info, err := os.Stat(w.cfg.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return w.openNew()
+	}
+	if err != nil {
+		return fmt.Errorf("rotate: stat %s: %w", w.cfg.Filename, err)
+	}
+	f, err := os.OpenFile(w.cfg.Filename, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("rotate: open %s: %w", w.cfg.Filename, err)
+	}
+	w.file = f
+	w.size = info.Size()
+	w.nextRotate = w.nextRotateAfter(w.now())
+	return nil
+}
+
+func (w *RotateWriter) openNew() error {
+	if err := os.MkdirAll(filepath.Dir(w.cfg.Filename), 0o755); err != nil {
+		return fmt.Errorf("rotate: mkdir: %w", err)
+	}
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("rotate: open %s: %w", w.cfg.Filename, err)
+	}
+	w.file = f
+	w.size = 0
+	w.nextRotate = w.nextRotateAfter(w.now())
+	return nil
+}
+
+// rotate 把当前文件重命名成备份文件，再打开一个新的
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("rotate: close: %w", err)
+		}
+		w.file = nil
+		if err := os.Rename(w.cfg.Filename, w.backupName(w.now())); err != nil {
+			return fmt.Errorf("rotate: rename: %w", err)
+		}
+	}
+	if err := w.openNew(); err != nil {
+		return err
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// nextRotateAfter 按本地时间对齐，例如 24h 就是每天零点切分
+func (w *RotateWriter) nextRotateAfter(t time.Time) time.Time {
+	if w.interval <= 0 {
+		return time.Time{}
+	}
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(w.interval).Add(w.interval).Add(-shift)
+}
+
+// backupName 生成备份文件名，同一毫秒切分多次的话往后顺延，避免覆盖
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	for {
+		name := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+		_, err := os.Stat(name)
+		_, gzErr := os.Stat(name + ".gz")
+		if errors.Is(err, os.ErrNotExist) && errors.Is(gzErr, os.ErrNotExist) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// nameParts 把 /var/log/app.log 拆成 /var/log、app-、.log
+func (w *RotateWriter) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.cfg.Filename)
+	base := filepath.Base(w.cfg.Filename)
+	ext = filepath.Ext(base)
+	prefix = strings.TrimSuffix(base, ext) + "-"
+	return
+}
+
+func (w *RotateWriter) millLoop() {
+	defer close(w.done)
+	for range w.millCh {
+		w.mill()
+	}
+}
+
+type backupFile struct {
+	path       string
+	t          time.Time
+	compressed bool
+}
+
+// mill 清理过期和超出数量的备份，再压缩剩下的
+// 这里的错误没有地方可以报告，只能忽略，下一次切分的时候会重试
+func (w *RotateWriter) mill() {
+	backups, err := w.backups()
+	if err != nil {
+		return
+	}
+	remaining := backups[:0]
+	for i, b := range backups {
+		expired := w.maxAge > 0 && w.now().Sub(b.t) > w.maxAge
+		overflow := w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups
+		if expired || overflow {
+			_ = os.Remove(b.path)
+			continue
+		}
+		remaining = append(remaining, b)
+	}
+	if !w.cfg.Compress {
+		return
+	}
+	for _, b := range remaining {
+		if !b.compressed {
+			_ = compressFile(b.path)
+		}
+	}
+}
+
+// backups 返回所有的备份文件，新的在前面
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []backupFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		b := backupFile{path: filepath.Join(dir, name)}
+		ts := strings.TrimPrefix(name, prefix)
+		if strings.HasSuffix(ts, ext+".gz") {
+			b.compressed = true
+			ts = strings.TrimSuffix(ts, ext+".gz")
+		} else if strings.HasSuffix(ts, ext) {
+			ts = strings.TrimSuffix(ts, ext)
+		} else {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+		b.t = t
+		res = append(res, b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].t.After(res[j].t)
+	})
+	return res, nil
+}
+
+// compressFile 压缩成 name.gz，成功之后删除原文件
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(RotateConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    1,
+		MaxBackups: 2,
+	})
+	require.NoError(t, err)
+	line := []byte(strings.Repeat("a", 1023) + "\n")
+	// 每个文件 1MB，写 4MB 多一点会切分 4 次，只留下 2 个备份
+	for i := 0; i < 1024*4+1; i++ {
+		_, err = w.Write(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	cur, err := os.Stat(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), cur.Size())
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	for _, b := range backups {
+		info, err := os.Stat(b)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1024*1024), info.Size())
+	}
+}
+
+func TestRotateWriter_IntervalAndCompress(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 23, 59, 0, 0, time.Local)
+	w, err := NewRotateWriter(RotateConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		Interval: "24h",
+		Compress: true,
+	})
+	require.NoError(t, err)
+	w.now = func() time.Time { return now }
+	w.nextRotate = w.nextRotateAfter(now)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local), w.nextRotate)
+
+	_, err = w.Write([]byte("day1\n"))
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = w.Write([]byte("day2\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "day2\n", string(data))
+
+	f, err := os.Open(filepath.Join(dir, "app-2026-10-18T00-00-00.000.log.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err = io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "day1\n", string(data))
+}
+
+func TestRotateWriter_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour * 48).Format(backupTimeFormat)
+	recent := time.Now().Add(-time.Hour).Format(backupTimeFormat)
+	for _, name := range []string{"app-" + old + ".log.gz", "app-" + recent + ".log", "other.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644))
+	}
+	// 已有的文件继续追加
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte("before\n"), 0o644))
+
+	w, err := NewRotateWriter(RotateConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxAge:   "24h",
+	})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"app-" + recent + ".log", "app.log", "other.log"}, names)
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\nafter\n", string(data))
+}
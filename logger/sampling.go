@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SamplingPolicy 某个级别的采样策略
+// 每个周期内，同一条消息前 First 条都会输出，之后每 Thereafter 条输出一条
+type SamplingPolicy struct {
+	First int64
+	// 0 代表超过 First 之后全部丢弃
+	Thereafter int64
+}
+
+// SamplingLogger 装饰器模式
+// 按照消息对日志进行采样，避免热点路径上的日志把日志系统打爆。
+// 没有配置策略的级别不采样。每个周期结束的时候会输出一条被丢弃的日志的统计
+type SamplingLogger struct {
+	l Logger
+	s *sampler
+}
+
+type sampler struct {
+	l        Logger
+	interval time.Duration
+	policies map[Level]SamplingPolicy
+
+	mu       sync.Mutex
+	counters map[samplingKey]*samplingCounter
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+type samplingKey struct {
+	level Level
+	msg   string
+}
+
+type samplingCounter struct {
+	windowStart time.Time
+	// 当前周期内的条数
+	cnt int64
+	// 上次输出统计之后丢弃的条数
+	dropped int64
+}
+
+// NewSamplingLogger 创建采样 Logger，用完之后需要调用 Close 停止输出统计
+// With 和 WithContext 派生出来的 Logger 和原来的共享计数。interval 小于等于 0 的时候使用 1s
+func NewSamplingLogger(l Logger, interval time.Duration, policies map[Level]SamplingPolicy) *SamplingLogger {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	s := &sampler{
+		l:        l,
+		interval: interval,
+		policies: policies,
+		counters: make(map[samplingKey]*samplingCounter),
+		closeCh:  make(chan struct{}),
+	}
+	go s.loop()
+	return &SamplingLogger{l: l, s: s}
+}
+
+// With implements Logger.
+func (s *SamplingLogger) With(args ...Field) Logger {
+	return &SamplingLogger{l: s.l.With(args...), s: s.s}
+}
+
+// WithContext implements LoggerV2.
+func (s *SamplingLogger) WithContext(ctx context.Context) Logger {
+	return &SamplingLogger{l: Ctx(ctx, s.l), s: s.s}
+}
+
+func (s *SamplingLogger) Debug(msg string, args ...Field) {
+	if s.s.allow(DebugLevel, msg) {
+		s.l.Debug(msg, args...)
+	}
+}
+
+func (s *SamplingLogger) Info(msg string, args ...Field) {
+	if s.s.allow(InfoLevel, msg) {
+		s.l.Info(msg, args...)
+	}
+}
+
+func (s *SamplingLogger) Warn(msg string, args ...Field) {
+	if s.s.allow(WarnLevel, msg) {
+		s.l.Warn(msg, args...)
+	}
+}
+
+func (s *SamplingLogger) Error(msg string, args ...Field) {
+	if s.s.allow(ErrorLevel, msg) {
+		s.l.Error(msg, args...)
+	}
+}
+
+// Close 停止定时输出统计，并且把还没输出的统计输出
+func (s *SamplingLogger) Close() error {
+	s.s.closeOnce.Do(func() {
+		close(s.s.closeCh)
+		s.s.report()
+	})
+	return nil
+}
+
+func (s *sampler) allow(level Level, msg string) bool {
+	p, ok := s.policies[level]
+	if !ok {
+		return true
+	}
+	now := time.Now()
+	key := samplingKey{level: level, msg: msg}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok {
+		c = &samplingCounter{windowStart: now}
+		s.counters[key] = c
+	}
+	if now.Sub(c.windowStart) >= s.interval {
+		c.windowStart = now
+		c.cnt = 0
+	}
+	c.cnt++
+	if c.cnt <= p.First {
+		return true
+	}
+	if p.Thereafter > 0 && (c.cnt-p.First)%p.Thereafter == 0 {
+		return true
+	}
+	c.dropped++
+	return false
+}
+
+func (s *sampler) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-ticker.C:
+			s.report()
+		}
+	}
+}
+
+// report 输出丢弃统计，顺便清理掉已经过期并且没有丢弃的计数，避免一直增长
+func (s *sampler) report() {
+	now := time.Now()
+	type dropped struct {
+		key samplingKey
+		cnt int64
+	}
+	var res []dropped
+	s.mu.Lock()
+	for key, c := range s.counters {
+		if c.dropped > 0 {
+			res = append(res, dropped{key: key, cnt: c.dropped})
+			c.dropped = 0
+			continue
+		}
+		if now.Sub(c.windowStart) >= s.interval {
+			delete(s.counters, key)
+		}
+	}
+	s.mu.Unlock()
+	// 不要在持有锁的时候打日志
+	for _, d := range res {
+		s.l.Warn("日志采样丢弃统计",
+			String("level", d.key.level.String()),
+			String("msg", d.key.msg),
+			Int64("dropped", d.cnt),
+			Duration("interval", s.interval))
+	}
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSamplingLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewSamplingLogger(NewZapLogger(zap.New(core)), time.Hour, map[Level]SamplingPolicy{
+		ErrorLevel: {First: 2, Thereafter: 3},
+		DebugLevel: {First: 1},
+	})
+
+	// With 派生出来的共享计数
+	withTopic := l.With(String("topic", "article"))
+	for i := 0; i < 10; i++ {
+		withTopic.Error("反序列化失败")
+		l.Debug("debug")
+		l.Info("not sampled")
+	}
+	l.Error("另一条消息")
+
+	assert.Equal(t, 4, logs.FilterMessage("反序列化失败").Len())
+	assert.Equal(t, 1, logs.FilterMessage("debug").Len())
+	assert.Equal(t, 10, logs.FilterMessage("not sampled").Len())
+	assert.Equal(t, 1, logs.FilterMessage("另一条消息").Len())
+
+	require.NoError(t, l.Close())
+	summary := logs.FilterMessage("日志采样丢弃统计").All()
+	require.Len(t, summary, 2)
+	dropped := map[string]int64{}
+	for _, e := range summary {
+		fields := e.ContextMap()
+		dropped[fields["msg"].(string)] = fields["dropped"].(int64)
+	}
+	assert.Equal(t, map[string]int64{"反序列化失败": 6, "debug": 9}, dropped)
+}
+
+func TestSamplingLogger_Interval(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewSamplingLogger(NewZapLogger(zap.New(core)), time.Millisecond*50, map[Level]SamplingPolicy{
+		WarnLevel: {First: 1},
+	})
+	defer l.Close()
+
+	l.Warn("retry")
+	l.Warn("retry")
+	// 下一个周期重新计数，并且定时输出了统计
+	time.Sleep(time.Millisecond * 80)
+	l.Warn("retry")
+	assert.Equal(t, 2, logs.FilterMessage("retry").Len())
+	assert.Eventually(t, func() bool {
+		return logs.FilterMessage("日志采样丢弃统计").Len() == 1
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestSamplingLogger_DefaultInterval(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	// interval 不合法的时候使用默认值，不会在后台的 goroutine 里 panic
+	l := NewSamplingLogger(NewZapLogger(zap.New(core)), 0, map[Level]SamplingPolicy{
+		WarnLevel: {First: 1},
+	})
+	defer l.Close()
+	assert.Equal(t, time.Second, l.s.interval)
+
+	l.Warn("retry")
+	l.Warn("retry")
+	assert.Equal(t, 1, logs.FilterMessage("retry").Len())
+}
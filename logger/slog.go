@@ -0,0 +1,208 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"time"
+)
+
+// SlogLogger 适配器模式
+// 用任意的 slog.Handler 实现 Logger 接口
+type SlogLogger struct {
+	h   slog.Handler
+	ctx context.Context
+}
+
+// NewSlogLogger 基于 slog.Handler 创建 Logger
+func NewSlogLogger(h slog.Handler) Logger {
+	return &SlogLogger{
+		h:   h,
+		ctx: context.Background(),
+	}
+}
+
+// With implements Logger.
+func (s *SlogLogger) With(args ...Field) Logger {
+	if len(args) == 0 {
+		return s
+	}
+	return &SlogLogger{
+		h:   s.h.WithAttrs(toSlogAttrs(args)),
+		ctx: s.ctx,
+	}
+}
+
+// WithContext implements LoggerV2.
+// ctx 会传给 slog.Handler 的 Handle，链路信息和请求字段会作为属性带上
+func (s *SlogLogger) WithContext(ctx context.Context) Logger {
+	h := s.h
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		h = h.WithAttrs(toSlogAttrs(fields))
+	}
+	return &SlogLogger{h: h, ctx: ctx}
+}
+
+func (s *SlogLogger) Debug(msg string, args ...Field) {
+	s.log(slog.LevelDebug, msg, args)
+}
+
+func (s *SlogLogger) Info(msg string, args ...Field) {
+	s.log(slog.LevelInfo, msg, args)
+}
+
+func (s *SlogLogger) Warn(msg string, args ...Field) {
+	s.log(slog.LevelWarn, msg, args)
+}
+
+func (s *SlogLogger) Error(msg string, args ...Field) {
+	s.log(slog.LevelError, msg, args)
+}
+
+func (s *SlogLogger) log(level slog.Level, msg string, args []Field) {
+	if !s.h.Enabled(s.ctx, level) {
+		return
+	}
+	// 跳过 runtime.Callers、log 和 Debug/Info 这一层，source 才能指向调用方
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.AddAttrs(toSlogAttrs(args)...)
+	_ = s.h.Handle(s.ctx, r)
+}
+
+func toSlogAttrs(fields []Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, toSlogAttr(f))
+	}
+	return attrs
+}
+
+func toSlogAttr(f Field) slog.Attr {
+	switch f.Type {
+	case StringType:
+		return slog.String(f.Key, f.String)
+	case Int64Type, Int32Type:
+		return slog.Int64(f.Key, f.Integer)
+	case Float64Type:
+		return slog.Float64(f.Key, math.Float64frombits(uint64(f.Integer)))
+	case BoolType:
+		return slog.Bool(f.Key, f.Integer == 1)
+	case DurationType:
+		return slog.Duration(f.Key, time.Duration(f.Integer))
+	default:
+		return slog.Any(f.Key, f.Value)
+	}
+}
+
+// SlogHandler 适配器模式
+// 用任意的 Logger 实现 slog.Handler 接口，分组会被展开成 group.key 这样的字段名
+type SlogHandler struct {
+	l     Logger
+	level slog.Leveler
+	// 当前所在的分组前缀，例如 "req.header."
+	prefix string
+}
+
+// NewSlogHandler 基于 Logger 创建 slog.Handler
+// level 为 nil 的时候所有级别都会交给 Logger 处理
+func NewSlogHandler(l Logger, level slog.Leveler) slog.Handler {
+	if level == nil {
+		level = slog.LevelDebug
+	}
+	return &SlogHandler{
+		l:     l,
+		level: level,
+	}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, a)
+		return true
+	})
+	l := h.l
+	if ctx != nil {
+		l = Ctx(ctx, l)
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		l.Error(r.Message, fields...)
+	case r.Level >= slog.LevelWarn:
+		l.Warn(r.Message, fields...)
+	case r.Level >= slog.LevelInfo:
+		l.Info(r.Message, fields...)
+	default:
+		l.Debug(r.Message, fields...)
+	}
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, h.prefix, a)
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	return &SlogHandler{
+		l:      h.l.With(fields...),
+		level:  h.level,
+		prefix: h.prefix,
+	}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{
+		l:      h.l,
+		level:  h.level,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+// appendSlogAttr 把 slog.Attr 转换为 Field，分组会递归展开
+func appendSlogAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	// 按照 slog 的约定，空的属性直接忽略
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		// key 为空的分组直接内联
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendSlogAttr(fields, groupPrefix, ga)
+		}
+		return fields
+	}
+	key := prefix + a.Key
+	v := a.Value
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, String(key, v.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(key, v.Int64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(key, v.Duration()))
+	default:
+		return append(fields, Field{Key: key, Value: v.Any()})
+	}
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewSlogLogger(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	l.Debug("dropped")
+	assert.Zero(t, buf.Len())
+
+	l.With(String("biz", "article")).Warn("hello",
+		Int64("id", 12), Error(errors.New("boom")), Duration("cost", time.Second))
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, "WARN", res["level"])
+	assert.Equal(t, "hello", res["msg"])
+	assert.Equal(t, "article", res["biz"])
+	assert.Equal(t, float64(12), res["id"])
+	assert.Equal(t, "boom", res["error"])
+	assert.Equal(t, float64(time.Second), res["cost"])
+
+	buf.Reset()
+	ctx := ContextWithFields(context.Background(), String("uid", "1"))
+	Ctx(ctx, l).Error("with ctx")
+	res = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, "1", res["uid"])
+}
+
+func TestSlogHandler(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	h := NewSlogHandler(NewZapLogger(zap.New(core)), slog.LevelInfo)
+	sl := slog.New(h)
+
+	sl.Debug("dropped")
+	assert.Equal(t, 0, logs.Len())
+
+	sl.With("service", "user").
+		WithGroup("req").
+		With("method", "GET").
+		Warn("slow request",
+			slog.Int("status", 200),
+			slog.Group("header", slog.String("ua", "curl")),
+			slog.Group("", slog.Bool("inline", true)),
+			slog.Group("empty"),
+			slog.Any("err", errors.New("boom")))
+	sl.Log(context.Background(), slog.LevelError+4, "fatal")
+	sl.Log(context.Background(), slog.LevelInfo+2, "between")
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "slow request", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, map[string]any{
+		"service":       "user",
+		"req.method":    "GET",
+		"req.status":    int64(200),
+		"req.header.ua": "curl",
+		"req.inline":    true,
+		"req.err":       "boom",
+	}, fields)
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, zapcore.InfoLevel, entries[2].Level)
+}
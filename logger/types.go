@@ -1,5 +1,7 @@
 package logger
 
+import "context"
+
 type Logger interface {
 	Debug(msg string, fields ...Field)
 	Info(msg string, fields ...Field)
@@ -7,7 +9,35 @@ type Logger interface {
 	Error(msg string, fields ...Field)
 	With(args ...Field) Logger
 }
-type Field struct {
-	Key   string
-	Value any
+
+// LoggerV2 能感知 context 的 Logger
+// WithContext 返回的 Logger 会自动带上 trace_id、span_id 和 ContextWithFields 放进去的字段
+type LoggerV2 interface {
+	Logger
+	WithContext(ctx context.Context) Logger
+}
+
+// Level 日志级别，对应 Logger 的四个方法
+type Level int8
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return "unknown"
+	}
 }
@@ -1,7 +1,14 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"math"
+	"syscall"
+	"time"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // 适配器模式
@@ -17,30 +24,84 @@ func (z *ZapLogger) With(args ...Field) Logger {
 	}
 }
 
+// WithContext implements LoggerV2.
+func (z *ZapLogger) WithContext(ctx context.Context) Logger {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return z
+	}
+	return z.With(fields...)
+}
+
 func NewZapLogger(l *zap.Logger) Logger {
 	return &ZapLogger{
 		l: l,
 	}
 }
 
+// 先判断级别，级别不够的时候不用转换字段
+// 这里不能再抽一层方法出来，否则 caller 会指向 ZapLogger 自己
 func (z *ZapLogger) Debug(msg string, args ...Field) {
-	z.l.Debug(msg, z.toZapFields(args)...)
+	if ce := z.l.Check(zapcore.DebugLevel, msg); ce != nil {
+		ce.Write(z.toZapFields(args)...)
+	}
 }
 func (z *ZapLogger) Info(msg string, args ...Field) {
-	z.l.Info(msg, z.toZapFields(args)...)
+	if ce := z.l.Check(zapcore.InfoLevel, msg); ce != nil {
+		ce.Write(z.toZapFields(args)...)
+	}
 }
 func (z *ZapLogger) Warn(msg string, args ...Field) {
-	z.l.Warn(msg, z.toZapFields(args)...)
+	if ce := z.l.Check(zapcore.WarnLevel, msg); ce != nil {
+		ce.Write(z.toZapFields(args)...)
+	}
 }
 func (z *ZapLogger) Error(msg string, args ...Field) {
-	z.l.Error(msg, z.toZapFields(args)...)
+	if ce := z.l.Check(zapcore.ErrorLevel, msg); ce != nil {
+		ce.Write(z.toZapFields(args)...)
+	}
+}
+
+// Sync 把 zap 缓冲的日志刷到输出里，AsyncLogger 的 Sync 和 Close 会调用它
+// 输出是 stdout、stderr 这种终端或者管道的时候 fsync 会返回 EINVAL、ENOTTY，这种错误直接忽略
+func (z *ZapLogger) Sync() error {
+	err := z.l.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+	return err
 }
 
-// 一个key 一个value
+// 一个key 一个value，按照类型转换成 zap 的强类型字段
 func (z *ZapLogger) toZapFields(args []Field) []zap.Field {
 	fields := make([]zap.Field, 0, len(args))
 	for _, v := range args {
-		fields = append(fields, zap.Any(v.Key, v.Value))
+		fields = append(fields, toZapField(v))
 	}
 	return fields
 }
+
+func toZapField(f Field) zap.Field {
+	switch f.Type {
+	case StringType:
+		return zap.String(f.Key, f.String)
+	case Int64Type:
+		return zap.Int64(f.Key, f.Integer)
+	case Int32Type:
+		return zap.Int32(f.Key, int32(f.Integer))
+	case Float64Type:
+		return zap.Float64(f.Key, math.Float64frombits(uint64(f.Integer)))
+	case BoolType:
+		return zap.Bool(f.Key, f.Integer == 1)
+	case DurationType:
+		return zap.Duration(f.Key, time.Duration(f.Integer))
+	case ErrorType:
+		err, _ := f.Value.(error)
+		return zap.NamedError(f.Key, err)
+	case BytesType:
+		b, _ := f.Value.([]byte)
+		return zap.Binary(f.Key, b)
+	default:
+		return zap.Any(f.Key, f.Value)
+	}
+}
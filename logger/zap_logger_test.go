@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLogger_TypedFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewZapLogger(zap.New(core))
+	errBoom := errors.New("boom")
+	l.Debug("disabled", String("k", "v"))
+	l.Info("typed",
+		String("s", "v"),
+		Int64("i64", 1),
+		Int32("i32", 2),
+		Float64("f", 1.5),
+		Bool("b", true),
+		Duration("d", time.Second),
+		Error(errBoom),
+		Bytes("bytes", []byte("x")),
+		Any("any", []int{1}),
+		// 老的写法仍然可以用
+		Field{Key: "evt", Value: map[string]int{"a": 1}},
+	)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]any{
+		"s":     "v",
+		"i64":   int64(1),
+		"i32":   int32(2),
+		"f":     1.5,
+		"b":     true,
+		"d":     time.Second,
+		"error": "boom",
+		"bytes": []byte("x"),
+		"any":   []any{1},
+		"evt":   map[string]int{"a": 1},
+	}, entries[0].ContextMap())
+}
+
+func TestField_Any(t *testing.T) {
+	errBoom := errors.New("boom")
+	testCases := []struct {
+		field Field
+		want  any
+	}{
+		{field: String("k", "v"), want: "v"},
+		{field: Int64("k", -1), want: int64(-1)},
+		{field: Int32("k", 2), want: int32(2)},
+		{field: Float64("k", -1.25), want: -1.25},
+		{field: Bool("k", true), want: true},
+		{field: Bool("k", false), want: false},
+		{field: Duration("k", time.Minute), want: time.Minute},
+		{field: Error(errBoom), want: errBoom},
+		{field: Bytes("k", []byte("x")), want: []byte("x")},
+		{field: Field{Key: "k", Value: 1}, want: 1},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, tc.field.Any())
+	}
+}
+
+func BenchmarkZapLogger(b *testing.B) {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	l := NewZapLogger(zap.New(zapcore.NewCore(enc, zapcore.AddSync(io.Discard), zapcore.DebugLevel)))
+	errBoom := errors.New("boom")
+	topic := strings.Repeat("article", 2)
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Info("消费消息",
+				String("topic", topic),
+				Int64("offset", int64(i)),
+				Duration("cost", time.Duration(i)),
+				Float64("ratio", float64(i)),
+				Error(errBoom))
+		}
+	})
+	// 改造之前的写法，所有的值都要装箱成 any，再交给 zap.Any 反射
+	b.Run("any", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Info("消费消息",
+				Field{Key: "topic", Value: topic},
+				Field{Key: "offset", Value: int64(i)},
+				Field{Key: "cost", Value: time.Duration(i)},
+				Field{Key: "ratio", Value: float64(i)},
+				Field{Key: "error", Value: errBoom})
+		}
+	})
+	// 级别不够的日志，core 是 Info 级别的，Debug 直接被过滤掉
+	b.Run("disabled", func(b *testing.B) {
+		l := NewZapLogger(zap.New(zapcore.NewCore(enc, zapcore.AddSync(io.Discard), zapcore.InfoLevel)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Debug("消费消息", String("topic", topic), Int64("offset", int64(i)))
+		}
+	})
+}
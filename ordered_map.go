@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"container/list"
+	"iter"
+)
+
+// OrderedMap 按插入顺序遍历的 map，非并发安全
+// 更新已有的 key 不会改变它的位置
+type OrderedMap[K comparable, V any] struct {
+	m map[K]*list.Element
+	// 按插入顺序保存 entry
+	l *list.List
+}
+
+type orderedEntry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// NewOrderedMap 创建一个按插入顺序遍历的 map
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{
+		m: make(map[K]*list.Element),
+		l: list.New(),
+	}
+}
+
+// Set 设置 key 对应的值
+func (om *OrderedMap[K, V]) Set(key K, val V) {
+	if e, ok := om.m[key]; ok {
+		e.Value.(*orderedEntry[K, V]).val = val
+		return
+	}
+	om.m[key] = om.l.PushBack(&orderedEntry[K, V]{key: key, val: val})
+}
+
+// Get 获取 key 对应的值
+func (om *OrderedMap[K, V]) Get(key K) (V, bool) {
+	e, ok := om.m[key]
+	if !ok {
+		return *new(V), false
+	}
+	return e.Value.(*orderedEntry[K, V]).val, true
+}
+
+// Delete 删除 key
+func (om *OrderedMap[K, V]) Delete(key K) {
+	if e, ok := om.m[key]; ok {
+		om.l.Remove(e)
+		delete(om.m, key)
+	}
+}
+
+// Len 返回元素个数
+func (om *OrderedMap[K, V]) Len() int {
+	return len(om.m)
+}
+
+// All 按插入顺序遍历
+func (om *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for e := om.l.Front(); e != nil; e = e.Next() {
+			entry := e.Value.(*orderedEntry[K, V])
+			if !yield(entry.key, entry.val) {
+				return
+			}
+		}
+	}
+}
+
+// Keys 按插入顺序返回所有的 key
+func (om *OrderedMap[K, V]) Keys() []K {
+	res := make([]K, 0, len(om.m))
+	for k := range om.All() {
+		res = append(res, k)
+	}
+	return res
+}
+
+// Values 按插入顺序返回所有的值
+func (om *OrderedMap[K, V]) Values() []V {
+	res := make([]V, 0, len(om.m))
+	for _, v := range om.All() {
+		res = append(res, v)
+	}
+	return res
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelMap 并发地将 src 转换为另一个类型的切片，结果和 src 的顺序一致
+// 最多同时运行 concurrency 个 goroutine，小于等于 0 代表不限制。
+// 任意一个元素出错就会取消 ctx，没开始的元素不再执行，返回第一个错误
+func ParallelMap[T any, U any](ctx context.Context, src []T, concurrency int,
+	fn func(ctx context.Context, idx int, src T) (U, error)) ([]U, error) {
+	dst := make([]U, len(src))
+	err := ParallelForEach(ctx, src, concurrency, func(ctx context.Context, idx int, s T) error {
+		u, err := fn(ctx, idx, s)
+		if err != nil {
+			return err
+		}
+		dst[idx] = u
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// ParallelMapAll 和 ParallelMap 类似，但是出错了不会取消其它元素，
+// 所有元素都会执行完，返回的错误是所有出错元素的错误合并起来的结果。
+// 出错的元素在结果里是零值
+func ParallelMapAll[T any, U any](ctx context.Context, src []T, concurrency int,
+	fn func(ctx context.Context, idx int, src T) (U, error)) ([]U, error) {
+	dst := make([]U, len(src))
+	err := ParallelForEachAll(ctx, src, concurrency, func(ctx context.Context, idx int, s T) error {
+		u, err := fn(ctx, idx, s)
+		if err != nil {
+			return err
+		}
+		dst[idx] = u
+		return nil
+	})
+	return dst, err
+}
+
+// ParallelForEach 并发地对 src 中的每个元素执行 fn，适合只有副作用的场景
+// 出错的处理和 ParallelMap 一样，快速失败
+func ParallelForEach[T any](ctx context.Context, src []T, concurrency int,
+	fn func(ctx context.Context, idx int, src T) error) error {
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(limitOf(concurrency))
+	scheduled := 0
+	for i, s := range src {
+		// 已经有元素出错了，或者 ctx 被取消了，后面的就不用再启动了
+		if egCtx.Err() != nil {
+			break
+		}
+		scheduled++
+		eg.Go(func() error {
+			if err := egCtx.Err(); err != nil {
+				return err
+			}
+			return fn(egCtx, i, s)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	// 没有元素出错，但是有元素没有执行，只能是调用方的 ctx 被取消了
+	if scheduled < len(src) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// ParallelForEachAll 并发地对 src 中的每个元素执行 fn，所有元素都会执行完
+// 出错的处理和 ParallelMapAll 一样，合并所有的错误
+func ParallelForEachAll[T any](ctx context.Context, src []T, concurrency int,
+	fn func(ctx context.Context, idx int, src T) error) error {
+	errs := make([]error, len(src))
+	var eg errgroup.Group
+	eg.SetLimit(limitOf(concurrency))
+	for i, s := range src {
+		eg.Go(func() error {
+			if err := fn(ctx, i, s); err != nil {
+				errs[i] = fmt.Errorf("index %d: %w", i, err)
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	return errors.Join(errs...)
+}
+
+// limitOf 转换成 errgroup 的并发限制，负数代表不限制
+func limitOf(concurrency int) int {
+	if concurrency <= 0 {
+		return -1
+	}
+	return concurrency
+}
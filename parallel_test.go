@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelMap(t *testing.T) {
+	src := make([]int, 100)
+	for i := range src {
+		src[i] = i
+	}
+	var running, maxRunning atomic.Int32
+	dst, err := ParallelMap(context.Background(), src, 4, func(ctx context.Context, idx int, v int) (string, error) {
+		cur := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := maxRunning.Load()
+			if cur <= old || maxRunning.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return strconv.Itoa(v), nil
+	})
+	require.NoError(t, err)
+	require.Len(t, dst, 100)
+	for i, v := range dst {
+		assert.Equal(t, strconv.Itoa(i), v)
+	}
+	assert.LessOrEqual(t, maxRunning.Load(), int32(4))
+}
+
+func TestParallelMap_FailFast(t *testing.T) {
+	src := make([]int, 100)
+	errBoom := errors.New("boom")
+	var called atomic.Int32
+	_, err := ParallelMap(context.Background(), src, 2, func(ctx context.Context, idx int, v int) (int, error) {
+		called.Add(1)
+		if idx == 3 {
+			return 0, errBoom
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Millisecond * 5):
+		}
+		return v, nil
+	})
+	assert.ErrorIs(t, err, errBoom)
+	// 出错之后不会把剩下的都跑一遍
+	assert.Less(t, called.Load(), int32(100))
+}
+
+func TestParallelMap_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dst, err := ParallelMap(ctx, []int{1, 2, 3}, 2, func(ctx context.Context, idx int, v int) (int, error) {
+		return v, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, dst)
+
+	// 执行的过程中被取消
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = ParallelForEach(ctx, make([]int, 100), 1, func(ctx context.Context, idx int, v int) error {
+		if idx == 3 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParallelMapAll(t *testing.T) {
+	src := []int{1, 2, 3, 4, 5}
+	errOdd := errors.New("odd")
+	dst, err := ParallelMapAll(context.Background(), src, 0, func(ctx context.Context, idx int, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v * 10, nil
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errOdd)
+	assert.Equal(t, "index 0: odd\nindex 2: odd\nindex 4: odd", err.Error())
+	assert.Equal(t, []int{0, 20, 0, 40, 0}, dst)
+}
+
+func TestParallelForEach(t *testing.T) {
+	var sum atomic.Int64
+	err := ParallelForEach(context.Background(), []int64{1, 2, 3, 4}, 2, func(ctx context.Context, idx int, v int64) error {
+		sum.Add(v)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), sum.Load())
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import "sync"
+
+// PriorityQueue 基于小顶堆的优先级队列
+// less(a, b) 返回 true 表示 a 比 b 先出队
+type PriorityQueue[T any] struct {
+	mu   sync.Mutex
+	heap *binaryHeap[T]
+}
+
+// NewPriorityQueue 创建优先级队列
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{
+		heap: newBinaryHeap(less),
+	}
+}
+
+// Enqueue 入队
+func (q *PriorityQueue[T]) Enqueue(v T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.heap.push(v)
+}
+
+// Dequeue 取出优先级最高的元素，队列为空时返回 false
+func (q *PriorityQueue[T]) Dequeue() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.len() == 0 {
+		return *new(T), false
+	}
+	return q.heap.pop(), true
+}
+
+// Peek 查看优先级最高的元素，队列为空时返回 false
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.len() == 0 {
+		return *new(T), false
+	}
+	return q.heap.peek(), true
+}
+
+// Len 返回队列长度
+func (q *PriorityQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.heap.len()
+}
+
+// Clear 清空队列
+func (q *PriorityQueue[T]) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.heap.items = nil
+}
+
+// binaryHeap 不加锁的二叉堆，由外层负责并发控制
+type binaryHeap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+func newBinaryHeap[T any](less func(a, b T) bool) *binaryHeap[T] {
+	return &binaryHeap[T]{less: less}
+}
+
+func (h *binaryHeap[T]) len() int {
+	return len(h.items)
+}
+
+func (h *binaryHeap[T]) peek() T {
+	return h.items[0]
+}
+
+func (h *binaryHeap[T]) push(v T) {
+	h.items = append(h.items, v)
+	h.up(len(h.items) - 1)
+}
+
+func (h *binaryHeap[T]) pop() T {
+	n := len(h.items) - 1
+	top := h.items[0]
+	h.items[0] = h.items[n]
+	// 帮助 GC
+	h.items[n] = *new(T)
+	h.items = h.items[:n]
+	if n > 0 {
+		h.down(0)
+	}
+	return top
+}
+
+func (h *binaryHeap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(h.items[i], h.items[parent]) {
+			break
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+func (h *binaryHeap[T]) down(i int) {
+	n := len(h.items)
+	for {
+		left := 2*i + 1
+		if left >= n {
+			break
+		}
+		child := left
+		if right := left + 1; right < n && h.less(h.items[right], h.items[left]) {
+			child = right
+		}
+		if !h.less(h.items[child], h.items[i]) {
+			break
+		}
+		h.items[i], h.items[child] = h.items[child], h.items[i]
+		i = child
+	}
+}
@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := NewPriorityQueue(func(a, b int) bool {
+		return a < b
+	})
+	_, ok := q.Dequeue()
+	require.False(t, ok)
+
+	for _, v := range []int{5, 3, 9, 1, 7, 3} {
+		q.Enqueue(v)
+	}
+	top, ok := q.Peek()
+	require.True(t, ok)
+	assert.Equal(t, 1, top)
+	assert.Equal(t, 6, q.Len())
+
+	res := make([]int, 0, q.Len())
+	for q.Len() > 0 {
+		v, _ := q.Dequeue()
+		res = append(res, v)
+	}
+	assert.Equal(t, []int{1, 3, 3, 5, 7, 9}, res)
+}
+
+func TestPriorityQueue_Concurrent(t *testing.T) {
+	q := NewPriorityQueue(func(a, b int) bool {
+		return a > b
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q.Enqueue(base*100 + j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 1000, q.Len())
+	prev := 1000
+	for q.Len() > 0 {
+		v, _ := q.Dequeue()
+		require.Less(t, v, prev)
+		prev = v
+	}
+}
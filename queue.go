@@ -1,6 +1,10 @@
 package pkg
 
-import "sync"
+import (
+	"iter"
+	"slices"
+	"sync"
+)
 
 type Queue[T any] struct {
 	sync.Mutex
@@ -47,3 +51,18 @@ func (q *Queue[T]) Clear() {
 	defer q.Unlock()
 	q.items = make([]T, 0)
 }
+
+// All 返回一个从队首到队尾的迭代器
+// 遍历的是调用时的快照，遍历过程中可以安全地修改队列
+func (q *Queue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		q.Lock()
+		items := slices.Clone(q.items)
+		q.Unlock()
+		for _, v := range items {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package adaptive
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/aegis/ratelimit"
+	"github.com/go-kratos/aegis/ratelimit/bbr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ ratelimit.Limiter = (*Limiter)(nil)
+
+// Stat 限流器当前的状态
+type Stat struct {
+	// 平滑之后的 CPU 使用率
+	CPU      float64
+	InFlight int64
+	// 按照最近的吞吐量和响应时间估算出来的最大并发
+	MaxInFlight int64
+	// 最近一个窗口里面，单个桶最多处理完了多少个请求
+	MaxPass int64
+	// 最近一个窗口里面，单个桶最小的平均响应时间
+	MinRT time.Duration
+}
+
+// Limiter 自适应限流，包装了 aegis 的 bbr：
+// CPU 使用率超过阈值的时候，如果正在处理的请求数超过了 最大吞吐量 x 最小响应时间，就拒绝新的请求，
+// 开始拒绝之后的 1s 内，即使 CPU 降下来了也继续按照并发判断，避免抖动。
+// aegis 的 CPU 采集方式是固定的，设置了 CPUSampler 之后，bbr 只按照并发判断，
+// 这里按照 CPUSampler 采集到的 CPU 决定要不要采纳 bbr 的判断
+type Limiter struct {
+	window       time.Duration
+	buckets      int
+	cpuThreshold float64
+
+	sampler        CPUSampler
+	sampleInterval time.Duration
+	// CPU 使用率的平滑系数，越大越平滑，跟 aegis 一样
+	decay float64
+
+	initOnce sync.Once
+	bbr      *bbr.BBR
+
+	// math.Float64bits 之后的 CPU 使用率
+	cpu atomic.Uint64
+	// 开始拒绝的时间，UnixNano，0 代表没有在拒绝
+	prevDrop atomic.Int64
+	// CPU 没有超过阈值，bbr 拒绝了但是放行了的请求数，这些请求不参与 bbr 的统计
+	overflow atomic.Int64
+
+	closeOnce sync.Once
+	stop      chan struct{}
+
+	dropped prometheus.Counter
+
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewLimiter 创建自适应限流器，默认 10s 的窗口分成 100 个桶，CPU 阈值 0.8，CPU 由 aegis 采集
+// 设置需要在使用之前完成，用完之后调用 Close
+func NewLimiter() *Limiter {
+	return &Limiter{
+		window:         time.Second * 10,
+		buckets:        100,
+		cpuThreshold:   0.8,
+		sampleInterval: time.Millisecond * 500,
+		decay:          0.95,
+		stop:           make(chan struct{}),
+		now:            time.Now,
+	}
+}
+
+// CPUSampler 替换 aegis 的 CPU 采集方式，例如 NewCgroupSampler、NewProcSampler
+// 或者从别的地方拿到的 CPU 使用率。
+// CPU 没有超过阈值的时候，超出 bbr 估算的并发的请求会被放行，但是不参与 bbr 的统计
+func (l *Limiter) CPUSampler(s CPUSampler) *Limiter {
+	l.sampler = s
+	return l
+}
+
+// SampleInterval 设置了 CPUSampler 的时候多久采集一次 CPU，默认 500ms
+func (l *Limiter) SampleInterval(d time.Duration) *Limiter {
+	l.sampleInterval = d
+	return l
+}
+
+// CPUThreshold CPU 使用率超过多少之后开始判断要不要拒绝，默认 0.8
+func (l *Limiter) CPUThreshold(threshold float64) *Limiter {
+	l.cpuThreshold = threshold
+	return l
+}
+
+// Window 统计吞吐量和响应时间的窗口，默认 10s 分成 100 个桶
+func (l *Limiter) Window(window time.Duration, buckets int) *Limiter {
+	if buckets <= 0 {
+		buckets = 100
+	}
+	l.window = window
+	l.buckets = buckets
+	return l
+}
+
+// Metrics 把 prometheus 指标注册到 reg 上：最大并发、当前并发、CPU 使用率和拒绝的次数，
+// reg 为 nil 的时候使用 prometheus.DefaultRegisterer。
+// 有多个 Limiter 的时候用不同的 subsystem，或者用 prometheus.WrapRegistererWith 加上不同的标签
+func (l *Limiter) Metrics(reg prometheus.Registerer, namespace, subsystem string) *Limiter {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	maxInFlight := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "adaptive_max_in_flight",
+		Help:      "自适应限流估算出来的最大并发",
+	}, func() float64 {
+		return float64(l.Stat().MaxInFlight)
+	})
+	inFlight := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "adaptive_in_flight",
+		Help:      "正在处理的请求数",
+	}, func() float64 {
+		return float64(l.Stat().InFlight)
+	})
+	cpu := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "adaptive_cpu_usage",
+		Help:      "平滑之后的 CPU 使用率",
+	}, func() float64 {
+		return l.Stat().CPU
+	})
+	l.dropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "adaptive_dropped_total",
+		Help:      "自适应限流拒绝的请求数",
+	})
+	reg.MustRegister(maxInFlight, inFlight, cpu, l.dropped)
+	return l
+}
+
+// Allow 判断要不要处理这个请求，拒绝的时候返回 ratelimit.ErrLimitExceed
+// 处理完之后需要调用返回的 DoneFunc
+func (l *Limiter) Allow() (ratelimit.DoneFunc, error) {
+	l.initOnce.Do(l.init)
+	done, err := l.bbr.Allow()
+	if err == nil || l.sampler == nil {
+		if err != nil {
+			l.onDrop()
+		}
+		return done, err
+	}
+	// bbr 按照并发拒绝了，再看 CPU
+	now := l.now().UnixNano()
+	if l.cpuUsage() >= l.cpuThreshold {
+		l.prevDrop.CompareAndSwap(0, now)
+		l.onDrop()
+		return nil, err
+	}
+	if prev := l.prevDrop.Load(); prev != 0 {
+		if now-prev <= int64(time.Second) {
+			l.onDrop()
+			return nil, err
+		}
+		l.prevDrop.CompareAndSwap(prev, 0)
+	}
+	l.overflow.Add(1)
+	return func(ratelimit.DoneInfo) {
+		l.overflow.Add(-1)
+	}, nil
+}
+
+// Stat 返回当前的状态
+func (l *Limiter) Stat() Stat {
+	l.initOnce.Do(l.init)
+	st := l.bbr.Stat()
+	res := Stat{
+		CPU:         float64(st.CPU) / 1000,
+		InFlight:    st.InFlight + l.overflow.Load(),
+		MaxInFlight: st.MaxInFlight,
+		MaxPass:     st.MaxPass,
+		MinRT:       time.Duration(st.MinRt) * time.Millisecond,
+	}
+	if l.sampler != nil {
+		res.CPU = l.cpuUsage()
+	}
+	return res
+}
+
+// Close 停止采集 CPU
+func (l *Limiter) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+	})
+	return nil
+}
+
+// init 第一次使用的时候创建 bbr，有 CPUSampler 的时候开始采集 CPU
+func (l *Limiter) init() {
+	// aegis 的阈值是千分比，阈值为 0 的时候 bbr 只按照并发判断
+	threshold := int64(math.Round(l.cpuThreshold * 1000))
+	if l.sampler != nil {
+		threshold = 0
+	}
+	l.bbr = bbr.NewLimiter(
+		bbr.WithWindow(l.window),
+		bbr.WithBucket(l.buckets),
+		bbr.WithCPUThreshold(threshold),
+	)
+	if l.sampler != nil && l.sampleInterval > 0 {
+		go l.sampling()
+	}
+}
+
+func (l *Limiter) onDrop() {
+	if l.dropped != nil {
+		l.dropped.Inc()
+	}
+}
+
+func (l *Limiter) cpuUsage() float64 {
+	return math.Float64frombits(l.cpu.Load())
+}
+
+// sampling 按照 decay 做指数平滑，跟 aegis 采集 CPU 的方式一样
+func (l *Limiter) sampling() {
+	ticker := time.NewTicker(l.sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		usage, err := l.sampler.Usage()
+		if err != nil {
+			continue
+		}
+		usage = math.Max(0, math.Min(usage, 1))
+		prev := l.cpuUsage()
+		l.cpu.Store(math.Float64bits(prev*l.decay + usage*(1-l.decay)))
+	}
+}
@@ -0,0 +1,88 @@
+package adaptive
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/aegis/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter()
+	defer l.Close()
+	done, err := l.Allow()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), l.Stat().InFlight)
+	done(ratelimit.DoneInfo{})
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+}
+
+type fakeSampler struct {
+	usage atomic.Uint64
+}
+
+func (f *fakeSampler) set(usage float64) {
+	f.usage.Store(math.Float64bits(usage))
+}
+
+func (f *fakeSampler) Usage() (float64, error) {
+	return math.Float64frombits(f.usage.Load()), nil
+}
+
+func TestLimiter_CPUSampler(t *testing.T) {
+	sampler := &fakeSampler{}
+	sampler.set(1)
+	now := time.Now()
+	l := NewLimiter().CPUSampler(sampler).SampleInterval(time.Millisecond)
+	l.now = func() time.Time { return now }
+	defer l.Close()
+
+	// 还没有统计数据的时候 bbr 估算出来的最大并发是 0，所以第三个请求开始就超过了
+	var dones []ratelimit.DoneFunc
+	for i := 0; i < 2; i++ {
+		done, err := l.Allow()
+		require.NoError(t, err)
+		dones = append(dones, done)
+	}
+	require.Eventually(t, func() bool {
+		return l.Stat().CPU >= 0.8
+	}, time.Second, time.Millisecond)
+	_, err := l.Allow()
+	assert.Equal(t, ratelimit.ErrLimitExceed, err)
+
+	// CPU 降下来之后的 1s 内继续拒绝
+	sampler.set(0)
+	require.Eventually(t, func() bool {
+		return l.Stat().CPU < 0.8
+	}, time.Second, time.Millisecond)
+	_, err = l.Allow()
+	assert.Equal(t, ratelimit.ErrLimitExceed, err)
+
+	// 之后 CPU 没有超过阈值，即使并发超过了也放行
+	now = now.Add(time.Second + time.Millisecond)
+	done, err := l.Allow()
+	require.NoError(t, err)
+	dones = append(dones, done)
+	assert.Equal(t, int64(3), l.Stat().InFlight)
+	for _, done := range dones {
+		done(ratelimit.DoneInfo{})
+	}
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+}
+
+func TestLimiter_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"http", "grpc"} {
+		assert.NotPanics(t, func() {
+			l := NewLimiter().Metrics(prometheus.WrapRegistererWith(prometheus.Labels{"limiter": name}, reg), "test", "adaptive")
+			defer l.Close()
+		})
+	}
+	_, err := reg.Gather()
+	require.NoError(t, err)
+}
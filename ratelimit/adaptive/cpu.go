@@ -0,0 +1,214 @@
+package adaptive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CPUSampler 采集 CPU 使用率
+type CPUSampler interface {
+	// Usage 返回从上一次调用到现在的 CPU 使用率，1 代表用满了分配给进程的 CPU，第一次调用返回 0
+	Usage() (float64, error)
+}
+
+// NewCPUSampler 优先按照 cgroup 采集，也就是容器分配到的 CPU，不在容器里面的话按照 /proc/stat 采集整机的
+// 都读不到（比如不是 Linux）的时候返回 error
+func NewCPUSampler() (CPUSampler, error) {
+	if s, err := NewCgroupSampler(); err == nil {
+		return s, nil
+	}
+	return NewProcSampler()
+}
+
+// cgroupSampler 按照 cgroup 的 CPU 使用时间和配额计算使用率，同时支持 cgroup v1 和 v2
+type cgroupSampler struct {
+	// 读取累计使用的 CPU 时间
+	usage func() (time.Duration, error)
+	// 分配到多少个核，可以是小数
+	cores float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	prevUsage time.Duration
+	prevTime  time.Time
+}
+
+// NewCgroupSampler 按照 cgroup 采集，使用率是相对于 cpu.max（v1 是 cfs_quota_us）的，没有配额的时候相对于所有的核
+func NewCgroupSampler() (CPUSampler, error) {
+	return newCgroupSampler("/sys/fs/cgroup")
+}
+
+func newCgroupSampler(root string) (*cgroupSampler, error) {
+	s := &cgroupSampler{now: time.Now}
+	if _, err := os.Stat(filepath.Join(root, "cpu.stat")); err == nil {
+		// cgroup v2，所有的控制器在同一个目录下面
+		s.usage = func() (time.Duration, error) {
+			return cgroupV2Usage(filepath.Join(root, "cpu.stat"))
+		}
+		s.cores = cgroupV2Cores(filepath.Join(root, "cpu.max"))
+	} else {
+		usageFile := filepath.Join(root, "cpuacct", "cpuacct.usage")
+		s.usage = func() (time.Duration, error) {
+			val, err := readUint(usageFile)
+			return time.Duration(val), err
+		}
+		s.cores = cgroupV1Cores(filepath.Join(root, "cpu"))
+	}
+	usage, err := s.usage()
+	if err != nil {
+		return nil, fmt.Errorf("adaptive: 读取 cgroup 失败 %w", err)
+	}
+	s.prevUsage, s.prevTime = usage, s.now()
+	return s, nil
+}
+
+func (s *cgroupSampler) Usage() (float64, error) {
+	usage, err := s.usage()
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := now.Sub(s.prevTime)
+	used := usage - s.prevUsage
+	s.prevUsage, s.prevTime = usage, now
+	if elapsed <= 0 || used < 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(elapsed) / s.cores, nil
+}
+
+// cgroupV2Usage 读取 cpu.stat 里面的 usage_usec
+func cgroupV2Usage(file string) (time.Duration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), " ")
+		if ok && key == "usage_usec" {
+			usec, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64)
+			return time.Duration(usec) * time.Microsecond, err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("adaptive: cpu.stat 里面没有 usage_usec")
+}
+
+// cgroupV2Cores cpu.max 的格式是 "配额 周期"，没有限制的时候配额是 max
+func cgroupV2Cores(file string) float64 {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return float64(runtime.NumCPU())
+	}
+	quota, period, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return coresOf(quota, period)
+}
+
+// cgroupV1Cores 读取 cpu.cfs_quota_us 和 cpu.cfs_period_us，没有限制的时候配额是 -1
+func cgroupV1Cores(dir string) float64 {
+	quota, err1 := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	period, err2 := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err1 != nil || err2 != nil {
+		return float64(runtime.NumCPU())
+	}
+	return coresOf(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func coresOf(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return float64(runtime.NumCPU())
+	}
+	return q / p
+}
+
+func readUint(file string) (uint64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// procSampler 按照 /proc/stat 计算整机的 CPU 使用率
+type procSampler struct {
+	file string
+
+	mu        sync.Mutex
+	prevIdle  uint64
+	prevTotal uint64
+}
+
+// NewProcSampler 按照 /proc/stat 采集整机的 CPU 使用率，不受 cgroup 配额的影响
+func NewProcSampler() (CPUSampler, error) {
+	return newProcSampler("/proc/stat")
+}
+
+func newProcSampler(file string) (*procSampler, error) {
+	s := &procSampler{file: file}
+	idle, total, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("adaptive: 读取 %s 失败 %w", file, err)
+	}
+	s.prevIdle, s.prevTotal = idle, total
+	return s, nil
+}
+
+func (s *procSampler) Usage() (float64, error) {
+	idle, total, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prevIdle, prevTotal := s.prevIdle, s.prevTotal
+	s.prevIdle, s.prevTotal = idle, total
+	if total <= prevTotal || idle < prevIdle || idle-prevIdle > total-prevTotal {
+		return 0, nil
+	}
+	return 1 - float64(idle-prevIdle)/float64(total-prevTotal), nil
+}
+
+// read 读取第一行 cpu user nice system idle iowait irq softirq steal ...，idle 包含 iowait
+func (s *procSampler) read() (idle, total uint64, err error) {
+	f, err := os.Open(s.file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, errors.New("adaptive: /proc/stat 是空的")
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("adaptive: 无法解析 %q", scanner.Text())
+	}
+	for i, field := range fields[1:] {
+		val, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += val
+		// 第 4 个是 idle，第 5 个是 iowait
+		if i == 3 || i == 4 {
+			idle += val
+		}
+	}
+	return idle, total, nil
+}
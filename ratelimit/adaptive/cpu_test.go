@@ -0,0 +1,89 @@
+package adaptive
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupSampler(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		// 每秒增加的 CPU 时间，单位微秒
+		update func(root string, usec int64)
+		want   float64
+	}{
+		{
+			name: "v2",
+			files: map[string]string{
+				"cpu.stat": "usage_usec 1000000\nuser_usec 0\n",
+				"cpu.max":  "200000 100000\n",
+			},
+			update: func(root string, usec int64) {
+				writeFile(t, filepath.Join(root, "cpu.stat"), "usage_usec "+itoa(1000000+usec)+"\n")
+			},
+			want: 0.5,
+		},
+		{
+			name: "v1",
+			files: map[string]string{
+				"cpuacct/cpuacct.usage": "1000000000",
+				"cpu/cpu.cfs_quota_us":  "400000",
+				"cpu/cpu.cfs_period_us": "100000",
+			},
+			update: func(root string, usec int64) {
+				writeFile(t, filepath.Join(root, "cpuacct/cpuacct.usage"), itoa(1000000000+usec*1000))
+			},
+			want: 0.25,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tc.files {
+				writeFile(t, filepath.Join(root, name), content)
+			}
+			s, err := newCgroupSampler(root)
+			require.NoError(t, err)
+			now := time.Now()
+			s.prevTime = now
+			s.now = func() time.Time { return now.Add(time.Second) }
+			// 1 秒里面用了 1 个核
+			tc.update(root, 1000000)
+			usage, err := s.Usage()
+			require.NoError(t, err)
+			assert.InDelta(t, tc.want, usage, 0.001)
+		})
+	}
+}
+
+func TestProcSampler(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stat")
+	writeFile(t, file, "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n")
+	s, err := newProcSampler(file)
+	require.NoError(t, err)
+
+	// 总共增加了 1000，其中 idle 和 iowait 增加了 250
+	writeFile(t, file, "cpu  400 0 550 900 150 0 0 0 0 0\n")
+	usage, err := s.Usage()
+	require.NoError(t, err)
+	assert.InDelta(t, 0.75, usage, 0.001)
+
+	_, err = newProcSampler(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func writeFile(t *testing.T, file, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
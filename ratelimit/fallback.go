@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FailurePolicy 主限流器不可用的时候怎么处理
+type FailurePolicy int8
+
+const (
+	// FailOpen 全部放行
+	FailOpen FailurePolicy = iota
+	// FailClosed 全部拒绝
+	FailClosed
+	// FailFallback 交给本地限流器，一般是按照实例数分摊全局阈值的单机限流器
+	FailFallback
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailOpen:
+		return "open"
+	case FailClosed:
+		return "closed"
+	case FailFallback:
+		return "fallback"
+	default:
+		return "unknown"
+	}
+}
+
+// FallbackLimiter 装饰器模式
+// 包装一个 Limiter（一般是 Redis 的），出错或者超时的时候按照 FailurePolicy 处理，而不是把错误返回给调用方。
+// 连续失败 FailureThreshold 次之后进入降级状态，降级期间不再访问主限流器，
+// 每隔 ProbeInterval 放一个请求去探测，探测成功就恢复。
+//
+// 本地限流器可以按照实例数分摊全局的阈值，例如全局每秒 1000 个，10 个实例：
+//
+//	ratelimit.NewFallbackLimiter(redisLimiter, ratelimit.FailFallback,
+//		local.NewTokenBucketLimiter(100, time.Second, 100))
+type FallbackLimiter struct {
+	primary Limiter
+	local   Limiter
+	policy  FailurePolicy
+
+	timeout          time.Duration
+	failureThreshold int32
+	probeInterval    time.Duration
+
+	degraded atomic.Bool
+	failures atomic.Int32
+	// 上一次探测的时间，UnixNano
+	lastProbe atomic.Int64
+
+	// 按照判定的来源和结果计数，来源是 primary、open、closed、fallback
+	decisions *prometheus.CounterVec
+	errors    prometheus.Counter
+	gauge     prometheus.Gauge
+
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewFallbackLimiter 创建降级限流器，policy 为 FailFallback 的时候 local 不能为空，否则 panic
+func NewFallbackLimiter(primary Limiter, policy FailurePolicy, local Limiter) *FallbackLimiter {
+	if policy == FailFallback && local == nil {
+		panic("ratelimit: FailFallback 需要本地限流器")
+	}
+	return &FallbackLimiter{
+		primary:          primary,
+		local:            local,
+		policy:           policy,
+		timeout:          time.Millisecond * 100,
+		failureThreshold: 3,
+		probeInterval:    time.Second,
+		now:              time.Now,
+	}
+}
+
+// Timeout 访问主限流器的超时时间，默认 100ms，0 代表只使用调用方的 ctx
+func (f *FallbackLimiter) Timeout(d time.Duration) *FallbackLimiter {
+	f.timeout = d
+	return f
+}
+
+// FailureThreshold 连续失败多少次之后进入降级状态，默认 3 次
+func (f *FallbackLimiter) FailureThreshold(n int) *FallbackLimiter {
+	if n < 1 {
+		n = 1
+	}
+	f.failureThreshold = int32(n)
+	return f
+}
+
+// ProbeInterval 降级期间多久探测一次主限流器，默认 1s
+func (f *FallbackLimiter) ProbeInterval(d time.Duration) *FallbackLimiter {
+	f.probeInterval = d
+	return f
+}
+
+// Metrics 把 prometheus 指标注册到 reg 上：判定的次数、主限流器出错的次数、是否处于降级状态，
+// reg 为 nil 的时候使用 prometheus.DefaultRegisterer。
+// 指标的名字是固定的，有多个 FallbackLimiter 的时候用不同的 subsystem，
+// 或者用 prometheus.WrapRegistererWith 给每一个加上不同的标签，否则重复注册会 panic
+func (f *FallbackLimiter) Metrics(reg prometheus.Registerer, namespace, subsystem string) *FallbackLimiter {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	f.decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "ratelimit_decisions_total",
+		Help:      "限流判定的次数，source 是判定的来源",
+	}, []string{"source", "allowed"})
+	f.errors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "ratelimit_primary_errors_total",
+		Help:      "主限流器出错或者超时的次数",
+	})
+	f.gauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "ratelimit_degraded",
+		Help:      "1 代表主限流器不可用，处于降级状态",
+	})
+	reg.MustRegister(f.decisions, f.errors, f.gauge)
+	return f
+}
+
+// Degraded 是否处于降级状态
+func (f *FallbackLimiter) Degraded() bool {
+	return f.degraded.Load()
+}
+
+func (f *FallbackLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := f.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+// Decide 主限流器出错的时候也不会返回 error，而是按照 FailurePolicy 判定
+func (f *FallbackLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	if f.degraded.Load() && !f.shouldProbe() {
+		return f.fallback(ctx, key), nil
+	}
+	pctx := ctx
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		pctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	d, err := Decide(pctx, f.primary, key)
+	if err != nil {
+		// 调用方自己取消了或者超时了，不能算主限流器不可用
+		if ctx.Err() == nil {
+			f.onFailure()
+		}
+		return f.fallback(ctx, key), nil
+	}
+	f.onSuccess()
+	f.record("primary", d.Allowed)
+	return d, nil
+}
+
+// shouldProbe 每个 ProbeInterval 只有一个请求会去探测
+func (f *FallbackLimiter) shouldProbe() bool {
+	now := f.now().UnixNano()
+	last := f.lastProbe.Load()
+	if now-last < int64(f.probeInterval) {
+		return false
+	}
+	return f.lastProbe.CompareAndSwap(last, now)
+}
+
+func (f *FallbackLimiter) onFailure() {
+	if f.errors != nil {
+		f.errors.Inc()
+	}
+	if f.failures.Add(1) < f.failureThreshold || f.degraded.Load() {
+		return
+	}
+	f.lastProbe.Store(f.now().UnixNano())
+	f.degraded.Store(true)
+	if f.gauge != nil {
+		f.gauge.Set(1)
+	}
+}
+
+func (f *FallbackLimiter) onSuccess() {
+	f.failures.Store(0)
+	if f.degraded.CompareAndSwap(true, false) && f.gauge != nil {
+		f.gauge.Set(0)
+	}
+}
+
+func (f *FallbackLimiter) fallback(ctx context.Context, key string) Decision {
+	var d Decision
+	switch f.policy {
+	case FailOpen:
+		d = Decision{Allowed: true}
+	case FailClosed:
+		d = Decision{RetryAfter: f.probeInterval}
+	default:
+		var err error
+		d, err = Decide(ctx, f.local, key)
+		// 本地限流器也出错的话就放行
+		if err != nil {
+			d = Decision{Allowed: true}
+		}
+	}
+	f.record(f.policy.String(), d.Allowed)
+	return d
+}
+
+func (f *FallbackLimiter) record(source string, allowed bool) {
+	if f.decisions != nil {
+		f.decisions.WithLabelValues(source, strconv.FormatBool(allowed)).Inc()
+	}
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyLimiter down 的时候返回错误，slow 的时候等到 ctx 超时
+type flakyLimiter struct {
+	down  atomic.Bool
+	slow  atomic.Bool
+	calls atomic.Int32
+}
+
+func (l *flakyLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	l.calls.Add(1)
+	if l.slow.Load() {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	if l.down.Load() {
+		return false, errors.New("redis: connection refused")
+	}
+	return false, nil
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	now := time.Now()
+	primary := &flakyLimiter{}
+	local := &countLimiter{}
+	l := NewFallbackLimiter(primary, FailFallback, local).
+		FailureThreshold(2).
+		ProbeInterval(time.Second).
+		Metrics(prometheus.NewRegistry(), "test", "fallback")
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	d, err := l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.False(t, l.Degraded())
+
+	primary.down.Store(true)
+	// 第一次失败就交给本地限流器了，但是还没有进入降级状态
+	d, err = l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.False(t, l.Degraded())
+	d, err = l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.True(t, l.Degraded())
+	assert.Equal(t, float64(1), testutil.ToFloat64(l.gauge))
+
+	// 降级期间不访问主限流器
+	calls := primary.calls.Load()
+	limited, err := l.Limit(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, limited)
+	assert.Equal(t, calls, primary.calls.Load())
+
+	// 探测失败，继续降级
+	now = now.Add(time.Second)
+	_, err = l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, calls+1, primary.calls.Load())
+	assert.True(t, l.Degraded())
+
+	// 探测成功，恢复
+	primary.down.Store(false)
+	_, err = l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, calls+1, primary.calls.Load())
+	now = now.Add(time.Second)
+	d, err = l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.False(t, l.Degraded())
+	assert.Equal(t, float64(0), testutil.ToFloat64(l.gauge))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(l.decisions.WithLabelValues("primary", "true")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(l.decisions.WithLabelValues("fallback", "true")))
+	assert.Equal(t, float64(4), testutil.ToFloat64(l.decisions.WithLabelValues("fallback", "false")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(l.errors))
+}
+
+func TestFallbackLimiter_Policy(t *testing.T) {
+	testCases := []struct {
+		policy  FailurePolicy
+		allowed bool
+	}{
+		{policy: FailOpen, allowed: true},
+		{policy: FailClosed, allowed: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			primary := &flakyLimiter{}
+			primary.slow.Store(true)
+			l := NewFallbackLimiter(primary, tc.policy, nil).Timeout(time.Millisecond * 10)
+			start := time.Now()
+			d, err := l.Decide(context.Background(), "key")
+			require.NoError(t, err)
+			assert.Equal(t, tc.allowed, d.Allowed)
+			// 超时也算失败
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
+}
+
+func TestFallbackLimiter_CallerCanceled(t *testing.T) {
+	primary := &flakyLimiter{}
+	primary.slow.Store(true)
+	l := NewFallbackLimiter(primary, FailOpen, nil).FailureThreshold(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d, err := l.Decide(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	// 调用方取消的不算主限流器失败
+	assert.False(t, l.Degraded())
+	assert.Equal(t, int32(0), l.failures.Load())
+}
+
+func TestFallbackLimiter_Validate(t *testing.T) {
+	assert.Panics(t, func() {
+		NewFallbackLimiter(&flakyLimiter{}, FailFallback, nil)
+	})
+}
+
+func TestFallbackLimiter_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// 多个限流器加上不同的标签注册到同一个 Registerer 上
+	for _, name := range []string{"sms", "login"} {
+		assert.NotPanics(t, func() {
+			NewFallbackLimiter(&flakyLimiter{}, FailOpen, nil).
+				Metrics(prometheus.WrapRegistererWith(prometheus.Labels{"limiter": name}, reg), "test", "fallback")
+		})
+	}
+}
@@ -0,0 +1,26 @@
+-- GCRA，每个 key 只存一个理论到达时间（TAT）
+local key = KEYS[1]
+-- 两个请求之间的间隔，微秒
+local emission = tonumber(ARGV[1])
+-- 允许的突发请求数
+local burst = tonumber(ARGV[2])
+-- 当前时间，微秒
+local now = tonumber(ARGV[3])
+
+if not emission or not burst or not now then
+    return redis.error_reply("invalid parameters")
+end
+
+local tat = tonumber(redis.call('GET', key))
+if not tat or tat < now then
+    tat = now
+end
+local newTat = tat + emission
+-- 最多可以提前 burst 个间隔
+local allowAt = newTat - emission * burst
+-- 返回 是否限流、剩余额度、多少微秒之后额度完全恢复、多少微秒之后可以重试
+if allowAt > now then
+    return {1, 0, tat - now, allowAt - now}
+end
+redis.call('SET', key, string.format('%d', newTat), 'PX', math.ceil((newTat - now) / 1000))
+return {0, math.floor((now - allowAt) / emission), newTat - now, 0}
@@ -0,0 +1,67 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+)
+
+// FixedWindowLimiter 固定窗口算法，每个 key 单独计数
+type FixedWindowLimiter struct {
+	*keyedStore[fixedWindowState]
+	// interval 内最多 rate 个请求
+	interval time.Duration
+	rate     int
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+type fixedWindowState struct {
+	// 当前窗口的起始时间
+	start time.Time
+	// 当前窗口放行的请求数
+	cnt int
+}
+
+// NewFixedWindowLimiter 每个 key 在 interval 内最多 rate 个请求
+func NewFixedWindowLimiter(interval time.Duration, rate int) *FixedWindowLimiter {
+	return &FixedWindowLimiter{
+		keyedStore: newKeyedStore[fixedWindowState](interval),
+		interval:   interval,
+		rate:       rate,
+		now:        time.Now,
+	}
+}
+
+// MaxKeys 最多保存多少个 key，超过之后淘汰最久没有访问的，默认是 DefaultMaxKeys
+func (l *FixedWindowLimiter) MaxKeys(n int) *FixedWindowLimiter {
+	l.setMaxKeys(n)
+	return l
+}
+
+func (l *FixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := l.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (l *FixedWindowLimiter) Decide(ctx context.Context, key string) (ratelimit.Decision, error) {
+	now := l.now()
+	d := ratelimit.Decision{Limit: l.rate}
+	l.do(key, now, func(st *fixedWindowState, fresh bool) {
+		// 要换窗口了
+		if fresh || !now.Before(st.start.Add(l.interval)) {
+			st.start = now
+			st.cnt = 0
+		}
+		d.ResetAt = st.start.Add(l.interval)
+		if st.cnt >= l.rate {
+			d.RetryAfter = d.ResetAt.Sub(now)
+			return
+		}
+		st.cnt++
+		d.Allowed = true
+		d.Remaining = l.rate - st.cnt
+	})
+	return d, nil
+}
@@ -0,0 +1,161 @@
+package local
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func assertLimit(t *testing.T, l ratelimit.Limiter, key string, want ...bool) {
+	t.Helper()
+	for i, w := range want {
+		limited, err := l.Limit(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, w, limited, "request %d", i)
+	}
+}
+
+func TestFixedWindowLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := NewFixedWindowLimiter(time.Second, 2)
+	l.now = clock.Now
+	start := clock.Now()
+
+	d, err := l.Decide(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Decision{Allowed: true, Limit: 2, Remaining: 1, ResetAt: start.Add(time.Second)}, d)
+	assertLimit(t, l, "a", false, true)
+	// 不同的 key 分开计数
+	assertLimit(t, l, "b", false)
+
+	clock.Add(time.Millisecond * 400)
+	d, err = l.Decide(context.Background(), "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Millisecond*600, d.RetryAfter)
+
+	clock.Add(time.Millisecond * 600)
+	assertLimit(t, l, "a", false, false, true)
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := NewSlidingWindowLimiter(time.Second, 2)
+	l.now = clock.Now
+	start := clock.Now()
+
+	assertLimit(t, l, "a", false)
+	clock.Add(time.Millisecond * 600)
+	assertLimit(t, l, "a", false)
+	d, err := l.Decide(context.Background(), "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Millisecond*400, d.RetryAfter)
+	assert.Equal(t, start.Add(time.Millisecond*1600), d.ResetAt)
+
+	// 第一个请求滑出窗口了，固定窗口的话这里会放行两个
+	clock.Add(time.Millisecond * 400)
+	assertLimit(t, l, "a", false, true)
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := NewTokenBucketLimiter(3, time.Millisecond*100, 1)
+	l.now = clock.Now
+	start := clock.Now()
+
+	d, err := l.Decide(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 2, ResetAt: start.Add(time.Millisecond * 100)}, d)
+	assertLimit(t, l, "a", false, false, true)
+
+	clock.Add(time.Millisecond * 30)
+	d, err = l.Decide(context.Background(), "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Millisecond*70, d.RetryAfter)
+
+	clock.Add(time.Millisecond * 70)
+	assertLimit(t, l, "a", false, true)
+	// 很久没有请求也最多只能突发 3 个
+	clock.Add(time.Minute)
+	assertLimit(t, l, "a", false, false, false, true)
+}
+
+func TestKeyedStore_Evict(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := NewFixedWindowLimiter(time.Second, 1).MaxKeys(2)
+	l.now = clock.Now
+
+	assertLimit(t, l, "a", false)
+	assertLimit(t, l, "b", false)
+	assertLimit(t, l, "a", true)
+	// a 刚访问过，淘汰的是 b
+	assertLimit(t, l, "c", false)
+	assert.Equal(t, 2, l.Len())
+	assertLimit(t, l, "a", true)
+	assertLimit(t, l, "b", false)
+
+	// 空闲超过窗口的 key 会被清理掉
+	clock.Add(time.Second)
+	assertLimit(t, l, "d", false)
+	assert.Equal(t, 1, l.Len())
+}
+
+func TestLimiters_Concurrent(t *testing.T) {
+	limiters := map[string]ratelimit.Limiter{
+		"fixed window":   NewFixedWindowLimiter(time.Hour, 10),
+		"sliding window": NewSlidingWindowLimiter(time.Hour, 10),
+		"token bucket":   NewTokenBucketLimiter(10, time.Hour, 1),
+	}
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				allowed = map[string]int{}
+			)
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					key := strconv.Itoa(i % 5)
+					limited, err := l.Limit(context.Background(), key)
+					assert.NoError(t, err)
+					if !limited {
+						mu.Lock()
+						allowed[key]++
+						mu.Unlock()
+					}
+				}(i)
+			}
+			wg.Wait()
+			for i := 0; i < 5; i++ {
+				assert.Equal(t, 10, allowed[strconv.Itoa(i)])
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+)
+
+// SlidingWindowLimiter 滑动窗口算法，每个 key 记录窗口内放行的请求的时间
+// 每个 key 需要 O(rate) 的内存，rate 很大的时候考虑用 TokenBucketLimiter
+type SlidingWindowLimiter struct {
+	*keyedStore[slidingWindowState]
+	// 窗口大小
+	interval time.Duration
+	// 阈值
+	rate int
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+type slidingWindowState struct {
+	// 窗口内放行的请求的时间，早的在前面
+	reqs []time.Time
+}
+
+// NewSlidingWindowLimiter 每个 key 在任意 interval 长度的窗口内最多 rate 个请求
+func NewSlidingWindowLimiter(interval time.Duration, rate int) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		keyedStore: newKeyedStore[slidingWindowState](interval),
+		interval:   interval,
+		rate:       rate,
+		now:        time.Now,
+	}
+}
+
+// MaxKeys 最多保存多少个 key，超过之后淘汰最久没有访问的，默认是 DefaultMaxKeys
+func (l *SlidingWindowLimiter) MaxKeys(n int) *SlidingWindowLimiter {
+	l.setMaxKeys(n)
+	return l
+}
+
+func (l *SlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := l.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (l *SlidingWindowLimiter) Decide(ctx context.Context, key string) (ratelimit.Decision, error) {
+	now := l.now()
+	d := ratelimit.Decision{Limit: l.rate}
+	l.do(key, now, func(st *slidingWindowState, fresh bool) {
+		// 删掉滑出窗口的请求
+		windowStart := now.Add(-l.interval)
+		i := 0
+		for i < len(st.reqs) && !st.reqs[i].After(windowStart) {
+			i++
+		}
+		if i > 0 {
+			st.reqs = st.reqs[:copy(st.reqs, st.reqs[i:])]
+		}
+		if len(st.reqs) >= l.rate {
+			// 最早的请求滑出窗口之后就可以重试了
+			d.RetryAfter = st.reqs[0].Add(l.interval).Sub(now)
+			d.ResetAt = st.reqs[len(st.reqs)-1].Add(l.interval)
+			return
+		}
+		st.reqs = append(st.reqs, now)
+		d.Allowed = true
+		d.Remaining = l.rate - len(st.reqs)
+		d.ResetAt = now.Add(l.interval)
+	})
+	return d, nil
+}
@@ -0,0 +1,97 @@
+package local
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys 默认最多保存多少个 key 的状态
+const DefaultMaxKeys = 10000
+
+// keyedStore 按 key 保存限流状态，超过 maxKeys 之后淘汰最久没有访问的 key，
+// 超过 idle 没有访问的 key 也会被顺便清理掉。
+// idle 之后的状态和新建的一样，所以清理掉不会影响限流的结果
+type keyedStore[S any] struct {
+	mu      sync.Mutex
+	maxKeys int
+	idle    time.Duration
+	// 最近访问的在前面
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type storeEntry[S any] struct {
+	key      string
+	state    S
+	lastSeen time.Time
+}
+
+func newKeyedStore[S any](idle time.Duration) *keyedStore[S] {
+	return &keyedStore[S]{
+		maxKeys: DefaultMaxKeys,
+		idle:    idle,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Len 返回当前保存了多少个 key 的状态
+func (s *keyedStore[S]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *keyedStore[S]) setMaxKeys(n int) {
+	if n <= 0 {
+		n = DefaultMaxKeys
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxKeys = n
+	for s.ll.Len() > s.maxKeys {
+		s.removeOldest()
+	}
+}
+
+// do 在锁里面操作 key 的状态，fresh 代表状态是新建的
+func (s *keyedStore[S]) do(key string, now time.Time, fn func(st *S, fresh bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.items[key]
+	fresh := !ok
+	if ok {
+		s.ll.MoveToFront(elem)
+	} else {
+		elem = s.ll.PushFront(&storeEntry[S]{key: key})
+		s.items[key] = elem
+	}
+	entry := elem.Value.(*storeEntry[S])
+	entry.lastSeen = now
+	fn(&entry.state, fresh)
+	s.evict(now)
+}
+
+// evict 淘汰超出数量的，再清理几个空闲的，每次只清理一部分，避免单次调用太慢
+func (s *keyedStore[S]) evict(now time.Time) {
+	for s.ll.Len() > s.maxKeys {
+		s.removeOldest()
+	}
+	for i := 0; i < 8; i++ {
+		back := s.ll.Back()
+		if back == nil || now.Sub(back.Value.(*storeEntry[S]).lastSeen) < s.idle {
+			return
+		}
+		s.removeOldest()
+	}
+}
+
+func (s *keyedStore[S]) removeOldest() {
+	back := s.ll.Back()
+	if back == nil {
+		return
+	}
+	s.ll.Remove(back)
+	delete(s.items, back.Value.(*storeEntry[S]).key)
+}
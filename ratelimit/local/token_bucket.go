@@ -0,0 +1,80 @@
+package local
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/Kirby980/go-pkg/ratelimit"
+)
+
+// TokenBucketLimiter 令牌桶算法，每个 key 一个桶
+// 令牌是在请求到来的时候按照经过的时间补充的，不需要后台的 goroutine
+type TokenBucketLimiter struct {
+	*keyedStore[tokenBucketState]
+	// 桶的容量
+	capacity int
+	// 每纳秒补充多少个令牌
+	perNano float64
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+type tokenBucketState struct {
+	tokens float64
+	// 上一次补充令牌的时间
+	last time.Time
+}
+
+// NewTokenBucketLimiter 桶的容量是 capacity，每 interval 补充 rate 个令牌
+func NewTokenBucketLimiter(capacity int, interval time.Duration, rate int) *TokenBucketLimiter {
+	perNano := float64(rate) / float64(interval)
+	// 桶从空到满的时间，之后的状态和新建的一样
+	full := time.Duration(math.Ceil(float64(capacity) / perNano))
+	return &TokenBucketLimiter{
+		keyedStore: newKeyedStore[tokenBucketState](full),
+		capacity:   capacity,
+		perNano:    perNano,
+		now:        time.Now,
+	}
+}
+
+// MaxKeys 最多保存多少个 key，超过之后淘汰最久没有访问的，默认是 DefaultMaxKeys
+func (l *TokenBucketLimiter) MaxKeys(n int) *TokenBucketLimiter {
+	l.setMaxKeys(n)
+	return l
+}
+
+func (l *TokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := l.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (l *TokenBucketLimiter) Decide(ctx context.Context, key string) (ratelimit.Decision, error) {
+	now := l.now()
+	d := ratelimit.Decision{Limit: l.capacity}
+	l.do(key, now, func(st *tokenBucketState, fresh bool) {
+		if fresh {
+			st.tokens = float64(l.capacity)
+			st.last = now
+		}
+		if now.After(st.last) {
+			st.tokens = math.Min(float64(l.capacity), st.tokens+float64(now.Sub(st.last))*l.perNano)
+			st.last = now
+		}
+		if st.tokens >= 1 {
+			st.tokens--
+			d.Allowed = true
+			d.Remaining = int(st.tokens)
+		} else {
+			d.RetryAfter = l.duration(1 - st.tokens)
+		}
+		d.ResetAt = now.Add(l.duration(float64(l.capacity) - st.tokens))
+	})
+	return d, nil
+}
+
+// duration 补充 tokens 个令牌需要的时间
+func (l *TokenBucketLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.perNano))
+}
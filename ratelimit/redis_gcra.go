@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed gcra.lua
+var luaGCRA string
+
+// RedisGCRALimiter 基于 Redis 的 GCRA（通用信元速率算法）
+// 效果和令牌桶差不多，但是每个 key 只需要存一个时间戳
+type RedisGCRALimiter struct {
+	cmd redis.Cmdable
+	// interval 内最多 rate 个请求
+	interval time.Duration
+	rate     int
+	// 允许的突发请求数
+	burst int
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewRedisGCRALimiter interval 内最多 rate 个请求，最多允许 burst 个请求同时到达
+// burst 小于 1 的时候按 1 处理，也就是请求必须均匀到达
+func NewRedisGCRALimiter(cmd redis.Cmdable, interval time.Duration, rate int, burst int) Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RedisGCRALimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		burst:    burst,
+		now:      time.Now,
+	}
+}
+
+func (r *RedisGCRALimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := r.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (r *RedisGCRALimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	now := r.now()
+	res, err := r.cmd.Eval(ctx, luaGCRA, []string{key},
+		r.emission().Microseconds(), r.burst, now.UnixMicro()).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return decisionFromScript(res, r.burst, time.Microsecond, now)
+}
+
+// emission 两个请求之间的间隔，最少 1 微秒
+func (r *RedisGCRALimiter) emission() time.Duration {
+	emission := r.interval / time.Duration(r.rate)
+	if emission < time.Microsecond {
+		emission = time.Microsecond
+	}
+	return emission
+}
@@ -0,0 +1,226 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis 设置了 REDIS_ADDR 的时候使用本地的 redis-server，否则使用 miniredis
+func newTestRedis(t *testing.T) redis.Cmdable {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(t).Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+// 这些限流器的效果都是：最多 3 个请求的突发，之后每 100ms 放行一个
+var redisLimiterCases = []struct {
+	name       string
+	newLimiter func(cmd redis.Cmdable, now func() time.Time) Limiter
+}{
+	{
+		name: "token bucket",
+		newLimiter: func(cmd redis.Cmdable, now func() time.Time) Limiter {
+			l := NewRedisTokenBucketLimiter(cmd, 3, time.Millisecond*100, 1).(*RedisTokenBucketLimiter)
+			l.now = now
+			return l
+		},
+	},
+	{
+		name: "gcra",
+		newLimiter: func(cmd redis.Cmdable, now func() time.Time) Limiter {
+			l := NewRedisGCRALimiter(cmd, time.Millisecond*300, 3, 3).(*RedisGCRALimiter)
+			l.now = now
+			return l
+		},
+	},
+}
+
+func TestRedisLimiters(t *testing.T) {
+	for _, tc := range redisLimiterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newTestRedis(t)
+			now := time.Now()
+			l := tc.newLimiter(cmd, func() time.Time { return now })
+			ctx := context.Background()
+			key := "limiter:" + uuid.NewString()
+			assertLimit := func(want ...bool) {
+				t.Helper()
+				for i, w := range want {
+					limited, err := l.Limit(ctx, key)
+					require.NoError(t, err)
+					assert.Equal(t, w, limited, "request %d", i)
+				}
+			}
+
+			// 突发
+			assertLimit(false, false, false, true, true)
+			// 补充了一个
+			now = now.Add(time.Millisecond * 100)
+			assertLimit(false, true)
+			now = now.Add(time.Millisecond * 50)
+			assertLimit(true)
+			now = now.Add(time.Millisecond * 50)
+			assertLimit(false, true)
+			// 很久没有请求也最多只能突发 3 个
+			now = now.Add(time.Minute)
+			assertLimit(false, false, false, true)
+
+			// 不同的 key 互不影响
+			other := tc.newLimiter(cmd, func() time.Time { return now })
+			limited, err := other.Limit(ctx, key+":other")
+			require.NoError(t, err)
+			assert.False(t, limited)
+
+			// 不再访问的 key 会过期，不会一直占用内存
+			ttl, err := cmd.PTTL(ctx, key).Result()
+			require.NoError(t, err)
+			assert.Greater(t, ttl, time.Duration(0))
+			assert.LessOrEqual(t, ttl, time.Millisecond*400)
+		})
+	}
+}
+
+func TestRedisLimiters_Concurrent(t *testing.T) {
+	for _, tc := range redisLimiterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newTestRedis(t)
+			now := time.Now()
+			l := tc.newLimiter(cmd, func() time.Time { return now })
+			key := "limiter:" + uuid.NewString()
+			results := make(chan bool, 20)
+			for i := 0; i < 20; i++ {
+				go func() {
+					limited, err := l.Limit(context.Background(), key)
+					assert.NoError(t, err)
+					results <- limited
+				}()
+			}
+			allowed := 0
+			for i := 0; i < 20; i++ {
+				if !<-results {
+					allowed++
+				}
+			}
+			// 脚本是原子的，并发的时候也不会多放行
+			assert.Equal(t, 3, allowed)
+		})
+	}
+}
+
+func TestRedisLimiters_Decide(t *testing.T) {
+	for _, tc := range redisLimiterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			l := tc.newLimiter(newTestRedis(t), func() time.Time { return now }).(DecisionLimiter)
+			ctx := context.Background()
+			key := "limiter:" + uuid.NewString()
+
+			d, err := l.Decide(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, d.Allowed)
+			assert.Equal(t, 3, d.Limit)
+			assert.Equal(t, 2, d.Remaining)
+			assert.WithinDuration(t, now.Add(time.Millisecond*100), d.ResetAt, time.Millisecond)
+			assert.Zero(t, d.RetryAfter)
+
+			_, err = l.Decide(ctx, key)
+			require.NoError(t, err)
+			d, err = l.Decide(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, d.Allowed)
+			assert.Equal(t, 0, d.Remaining)
+			assert.WithinDuration(t, now.Add(time.Millisecond*300), d.ResetAt, time.Millisecond)
+
+			now = now.Add(time.Millisecond * 30)
+			d, err = l.Decide(ctx, key)
+			require.NoError(t, err)
+			assert.False(t, d.Allowed)
+			assert.Equal(t, 0, d.Remaining)
+			assert.InDelta(t, time.Millisecond*70, d.RetryAfter, float64(time.Millisecond))
+			assert.WithinDuration(t, now.Add(time.Millisecond*270), d.ResetAt, time.Millisecond)
+		})
+	}
+}
+
+func TestRedisSlidingWindowLimiter_Decide(t *testing.T) {
+	now := time.Now()
+	l := NewRedisSlidingWindowLimiter(newTestRedis(t), time.Second, 2).(*RedisSlidingWindowLimiter)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+	key := "limiter:" + uuid.NewString()
+	start := now
+
+	d, err := l.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true, Limit: 2, Remaining: 1, ResetAt: now.Add(time.Second)}, d)
+
+	now = now.Add(time.Millisecond * 100)
+	d, err = l.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+
+	now = now.Add(time.Millisecond * 100)
+	d, err = l.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	// 第一个请求滑出窗口之后就可以重试
+	assert.Equal(t, time.Millisecond*800, d.RetryAfter)
+	assert.Equal(t, start.Add(time.Millisecond*1100).UnixMilli(), d.ResetAt.UnixMilli())
+
+	limited, err := l.Limit(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, limited)
+}
+
+func TestRedisSlidingWindowLimiter_SameMillisecond(t *testing.T) {
+	now := time.Now()
+	l := NewRedisSlidingWindowLimiter(newTestRedis(t), time.Second, 3).(*RedisSlidingWindowLimiter)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+	key := "limiter:" + uuid.NewString()
+
+	// 同一毫秒内的请求每个都要计数
+	for i := 0; i < 3; i++ {
+		limited, err := l.Limit(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, limited)
+	}
+	limited, err := l.Limit(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, limited)
+}
+
+// countLimiter 没有实现 DecisionLimiter
+type countLimiter struct {
+	n int
+}
+
+func (c *countLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	c.n++
+	return c.n > 1, nil
+}
+
+func TestDecide(t *testing.T) {
+	l := &countLimiter{}
+	d, err := Decide(context.Background(), l, "key")
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true}, d)
+	d, err = Decide(context.Background(), l, "key")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+}
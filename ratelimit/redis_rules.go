@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed rules.lua
+var luaRules string
+
+// RedisRuleLimiter 基于 Redis 的多规则限流
+// 一个请求适用的所有规则在一次 lua 调用里面判定，所有规则都通过才会消耗额度。
+// 每条规则是一个 GCRA，Interval 内最多 Rate 个请求，允许 Rate 个请求的突发。
+// 使用 Redis Cluster 的时候，prefix 需要带上 hash tag，例如 {ratelimit}，保证所有的 key 在同一个 slot
+type RedisRuleLimiter struct {
+	cmd    redis.Cmdable
+	prefix string
+	rules  atomic.Pointer[[]Rule]
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewRedisRuleLimiter 创建多规则限流器，key 的格式是 prefix:规则名:维度的值
+func NewRedisRuleLimiter(cmd redis.Cmdable, prefix string, rules ...Rule) (*RedisRuleLimiter, error) {
+	r := &RedisRuleLimiter{
+		cmd:    cmd,
+		prefix: prefix,
+		now:    time.Now,
+	}
+	if err := r.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetRules 原子地替换所有规则，规则不合法的时候保留原来的规则
+func (r *RedisRuleLimiter) SetRules(rules []Rule) error {
+	if err := ValidateRules(rules); err != nil {
+		return err
+	}
+	rules = append([]Rule(nil), rules...)
+	r.rules.Store(&rules)
+	return nil
+}
+
+// Rules 返回当前的规则
+func (r *RedisRuleLimiter) Rules() []Rule {
+	return append([]Rule(nil), *r.rules.Load()...)
+}
+
+func (r *RedisRuleLimiter) DecideRequest(ctx context.Context, req Request) (RuleDecision, error) {
+	rules := *r.rules.Load()
+	now := r.now()
+	matched := make([]Rule, 0, len(rules))
+	keys := make([]string, 0, len(rules))
+	args := make([]any, 0, len(rules)*2+1)
+	args = append(args, now.UnixMicro())
+	for _, rule := range rules {
+		val, ok := rule.value(req)
+		if !ok {
+			continue
+		}
+		emission := rule.Interval / time.Duration(rule.Rate)
+		if emission < time.Microsecond {
+			emission = time.Microsecond
+		}
+		matched = append(matched, rule)
+		keys = append(keys, fmt.Sprintf("%s:%s:%s", r.prefix, rule.Name, val))
+		args = append(args, emission.Microseconds(), rule.Rate)
+	}
+	if len(matched) == 0 {
+		return RuleDecision{Decision: Decision{Allowed: true}}, nil
+	}
+	res, err := r.cmd.Eval(ctx, luaRules, keys, args...).Int64Slice()
+	if err != nil {
+		return RuleDecision{}, err
+	}
+	if len(res) != 5 || res[1] < 1 || int(res[1]) > len(matched) {
+		return RuleDecision{}, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+	rule := matched[res[1]-1]
+	d, err := decisionFromScript([]int64{res[0], res[2], res[3], res[4]}, rule.Rate, time.Microsecond, now)
+	return RuleDecision{Decision: d, Rule: rule.Name}, err
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRules(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{
+			name: "合法",
+			rules: []Rule{
+				{Name: "global", Dimension: DimGlobal, Interval: time.Second, Rate: 100},
+				{Name: "app", Dimension: DimHeader, Header: "X-App-Key", Interval: time.Second, Rate: 10},
+			},
+		},
+		{
+			name:    "没有名字",
+			rules:   []Rule{{Dimension: DimGlobal, Interval: time.Second, Rate: 1}},
+			wantErr: true,
+		},
+		{
+			name:    "阈值不合法",
+			rules:   []Rule{{Name: "ip", Dimension: DimIP, Interval: time.Second}},
+			wantErr: true,
+		},
+		{
+			name:    "请求头维度没有请求头",
+			rules:   []Rule{{Name: "app", Dimension: DimHeader, Interval: time.Second, Rate: 1}},
+			wantErr: true,
+		},
+		{
+			name:    "未知的维度",
+			rules:   []Rule{{Name: "x", Dimension: "cookie", Interval: time.Second, Rate: 1}},
+			wantErr: true,
+		},
+		{
+			name: "名字重复",
+			rules: []Rule{
+				{Name: "ip", Dimension: DimIP, Interval: time.Second, Rate: 1},
+				{Name: "ip", Dimension: DimIP, Interval: time.Minute, Rate: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRules(tc.rules)
+			assert.Equal(t, tc.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestRedisRuleLimiter(t *testing.T) {
+	now := time.Now()
+	l, err := NewRedisRuleLimiter(newTestRedis(t), "limiter:"+t.Name(),
+		Rule{Name: "ip", Dimension: DimIP, Interval: time.Minute, Rate: 5},
+		Rule{Name: "user", Dimension: DimUser, Interval: time.Minute, Rate: 2},
+		Rule{Name: "login", Dimension: DimRoute, Match: "/login", Interval: time.Minute, Rate: 3},
+	)
+	require.NoError(t, err)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 用户维度剩余的最少
+	d, err := l.DecideRequest(ctx, Request{Method: "GET", Route: "/profile", UserID: "1", IP: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, "user", d.Rule)
+	assert.Equal(t, 2, d.Limit)
+	assert.Equal(t, 1, d.Remaining)
+
+	d, err = l.DecideRequest(ctx, Request{Method: "GET", Route: "/profile", UserID: "1", IP: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	d, err = l.DecideRequest(ctx, Request{Method: "GET", Route: "/profile", UserID: "1", IP: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "user", d.Rule)
+	assert.Equal(t, 30*time.Second, d.RetryAfter)
+
+	// 被用户维度拦下来的请求不消耗 IP 维度的额度，同一个 IP 还能再放 3 个没有登录的请求
+	for i := 0; i < 3; i++ {
+		d, err = l.DecideRequest(ctx, Request{Method: "GET", Route: "/profile", IP: "1.1.1.1"})
+		require.NoError(t, err)
+		assert.True(t, d.Allowed, i)
+	}
+	d, err = l.DecideRequest(ctx, Request{Method: "GET", Route: "/profile", IP: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "ip", d.Rule)
+
+	// 路由规则只对匹配的路由生效
+	for i := 0; i < 3; i++ {
+		d, err = l.DecideRequest(ctx, Request{Method: "POST", Route: "/login", IP: "2.2.2.2"})
+		require.NoError(t, err)
+		assert.True(t, d.Allowed, i)
+	}
+	d, err = l.DecideRequest(ctx, Request{Method: "POST", Route: "/login", IP: "2.2.2.2"})
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "login", d.Rule)
+
+	// 额度恢复之后放行
+	now = now.Add(time.Minute)
+	d, err = l.DecideRequest(ctx, Request{Method: "POST", Route: "/login", IP: "2.2.2.2"})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+}
+
+func TestRedisRuleLimiter_SetRules(t *testing.T) {
+	l, err := NewRedisRuleLimiter(newTestRedis(t), "limiter:"+t.Name())
+	require.NoError(t, err)
+
+	// 没有规则的时候全部放行
+	d, err := l.DecideRequest(context.Background(), Request{Route: "/hello"})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Empty(t, d.Rule)
+
+	rules := []Rule{{Name: "global", Dimension: DimGlobal, Interval: time.Minute, Rate: 1}}
+	require.NoError(t, l.SetRules(rules))
+	// 不合法的规则不会替换掉原来的
+	assert.Error(t, l.SetRules([]Rule{{Name: "global"}}))
+	assert.Equal(t, rules, l.Rules())
+
+	d, err = l.DecideRequest(context.Background(), Request{Route: "/hello"})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	d, err = l.DecideRequest(context.Background(), Request{Route: "/world"})
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "global", d.Rule)
+}
@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed semaphore_acquire.lua
+	luaSemaphoreAcquire string
+	//go:embed semaphore_release.lua
+	luaSemaphoreRelease string
+	//go:embed semaphore_refresh.lua
+	luaSemaphoreRefresh string
+)
+
+var (
+	// ErrNoPermit 没有空闲的许可
+	ErrNoPermit = errors.New("ratelimit: no permit available")
+	// ErrPermitLost 许可的租约已经过期，或者已经被释放了
+	ErrPermitLost = errors.New("ratelimit: permit lost")
+)
+
+// Semaphore 信号量，限制同时在处理的请求数
+type Semaphore interface {
+	// Acquire 拿到一个许可，没有空闲的许可就排队等待，直到 ctx 过期
+	Acquire(ctx context.Context, key string) (Permit, error)
+	// TryAcquire 不排队，没有空闲的许可返回 ErrNoPermit
+	TryAcquire(ctx context.Context, key string) (Permit, error)
+}
+
+// Permit 信号量的许可，用完之后需要 Release
+type Permit interface {
+	Release(ctx context.Context) error
+	// Refresh 续约，处理时间可能超过租约的时候需要定时调用，或者使用 KeepAlive
+	Refresh(ctx context.Context) error
+	// Lease 租约的长度，0 代表不会过期，不需要续约
+	Lease() time.Duration
+}
+
+// KeepAlive 每隔 Lease 的三分之一续约一次，直到调用返回的 stop 或者许可已经丢失了。
+// 续约失败的时候调用 onErr，onErr 可以是 nil。stop 会等到续约的 goroutine 退出之后才返回，
+// 所以在 stop 之后 Release 不会跟续约并发
+func KeepAlive(p Permit, onErr func(err error)) (stop func()) {
+	interval := p.Lease() / 3
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := p.Refresh(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			if onErr != nil {
+				onErr(err)
+			}
+			if errors.Is(err, ErrPermitLost) {
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// RedisSemaphore 基于 Redis 的分布式信号量，整个集群同一个 key 最多 permits 个许可。
+// 每个许可都有租约，持有者挂了之后租约到期会自动释放。
+// 排队是公平的，先来的先拿到许可，排队的过程中每 PollInterval 检查一次，
+// 不再检查的排队者（比如进程挂了）会被移出队列。
+// 租约和心跳都按照 Redis 的时间计算，不要求各个实例的时钟一致。
+// 除了 key 本身之外还会用到 key:queue、key:alive、key:seq 三个 key，
+// 使用 Redis Cluster 的时候 key 需要带上 hash tag，例如 {openim}
+type RedisSemaphore struct {
+	cmd          redis.Cmdable
+	permits      int
+	lease        time.Duration
+	pollInterval time.Duration
+}
+
+// NewRedisSemaphore 创建信号量，默认租约 30s，排队的时候每 50ms 检查一次
+func NewRedisSemaphore(cmd redis.Cmdable, permits int) *RedisSemaphore {
+	return &RedisSemaphore{
+		cmd:          cmd,
+		permits:      permits,
+		lease:        time.Second * 30,
+		pollInterval: time.Millisecond * 50,
+	}
+}
+
+// Lease 许可的租约，需要比处理一个请求的最长时间长，或者使用 KeepAlive 定时续约
+func (s *RedisSemaphore) Lease(d time.Duration) *RedisSemaphore {
+	s.lease = d
+	return s
+}
+
+// PollInterval 排队的时候多久检查一次
+func (s *RedisSemaphore) PollInterval(d time.Duration) *RedisSemaphore {
+	s.pollInterval = d
+	return s
+}
+
+func (s *RedisSemaphore) Acquire(ctx context.Context, key string) (Permit, error) {
+	token := uuid.NewString()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := s.acquire(ctx, key, token, true)
+		if err != nil {
+			s.abandon(key, token)
+			return nil, err
+		}
+		if ok {
+			return s.permit(key, token), nil
+		}
+		select {
+		case <-ctx.Done():
+			s.abandon(key, token)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *RedisSemaphore) TryAcquire(ctx context.Context, key string) (Permit, error) {
+	token := uuid.NewString()
+	ok, err := s.acquire(ctx, key, token, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoPermit
+	}
+	return s.permit(key, token), nil
+}
+
+func (s *RedisSemaphore) acquire(ctx context.Context, key, token string, wait bool) (bool, error) {
+	waitArg := 0
+	if wait {
+		waitArg = 1
+	}
+	// 错过三次检查就认为排队者不在了
+	aliveTTL := s.pollInterval*3 + time.Second
+	res, err := s.cmd.Eval(ctx, luaSemaphoreAcquire, s.keys(key),
+		s.permits, s.lease.Milliseconds(), token, waitArg, aliveTTL.Milliseconds()).Int()
+	return res == 1, err
+}
+
+// abandon 不再排队，ctx 可能已经过期了，所以用新的 ctx
+// 失败了也没关系，心跳超时之后会被移出队列
+func (s *RedisSemaphore) abandon(key, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.cmd.Eval(ctx, luaSemaphoreRelease, s.keys(key)[:3], token).Err()
+}
+
+func (s *RedisSemaphore) keys(key string) []string {
+	return []string{key, key + ":queue", key + ":alive", key + ":seq"}
+}
+
+func (s *RedisSemaphore) permit(key, token string) *RedisPermit {
+	return &RedisPermit{s: s, key: key, token: token}
+}
+
+// RedisPermit RedisSemaphore 的许可
+type RedisPermit struct {
+	s     *RedisSemaphore
+	key   string
+	token string
+}
+
+// Release 释放许可，租约已经过期的时候返回 ErrPermitLost
+func (p *RedisPermit) Release(ctx context.Context) error {
+	res, err := p.s.cmd.Eval(ctx, luaSemaphoreRelease, p.s.keys(p.key)[:3], p.token).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrPermitLost
+	}
+	return nil
+}
+
+// Refresh 把租约延长到从现在开始的一个 Lease，租约已经过期的时候返回 ErrPermitLost
+func (p *RedisPermit) Refresh(ctx context.Context) error {
+	res, err := p.s.cmd.Eval(ctx, luaSemaphoreRefresh, []string{p.key},
+		p.s.lease.Milliseconds(), p.token).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrPermitLost
+	}
+	return nil
+}
+
+func (p *RedisPermit) Lease() time.Duration {
+	return p.s.lease
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisSemaphore(t *testing.T) {
+	// 租约按照 Redis 的时间计算，所以这里控制 miniredis 的时间
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	now := time.Now()
+	mr.SetTime(now)
+	s := NewRedisSemaphore(client, 2).Lease(time.Second)
+	ctx := context.Background()
+	key := "semaphore:" + t.Name()
+
+	p1, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	p2, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	_, err = s.TryAcquire(ctx, key)
+	assert.Equal(t, ErrNoPermit, err)
+
+	require.NoError(t, p1.Release(ctx))
+	// 重复释放
+	assert.Equal(t, ErrPermitLost, p1.Release(ctx))
+	p3, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+
+	// 续约之后 p2 不会过期，没有续约的 p3 过期之后许可自动回收
+	mr.SetTime(now.Add(time.Millisecond * 500))
+	require.NoError(t, p2.Refresh(ctx))
+	mr.SetTime(now.Add(time.Millisecond * 1100))
+	assert.Equal(t, ErrPermitLost, p3.Refresh(ctx))
+	p4, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	_, err = s.TryAcquire(ctx, key)
+	assert.Equal(t, ErrNoPermit, err)
+
+	require.NoError(t, p2.Release(ctx))
+	require.NoError(t, p4.Release(ctx))
+	assert.Equal(t, ErrPermitLost, p3.Release(ctx))
+}
+
+func TestRedisSemaphore_Fair(t *testing.T) {
+	s := NewRedisSemaphore(newTestRedis(t), 1).PollInterval(time.Millisecond * 10)
+	ctx := context.Background()
+	key := "semaphore:" + t.Name()
+
+	holder, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+
+	type result struct {
+		p   Permit
+		err error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		p, err := s.Acquire(ctx, key)
+		waiter <- result{p: p, err: err}
+	}()
+	// 等到排上队
+	require.Eventually(t, func() bool {
+		n, err := s.cmd.ZCard(ctx, key+":queue").Result()
+		return err == nil && n == 1
+	}, time.Second, time.Millisecond*5)
+
+	// 释放之后，后来的不能插队
+	require.NoError(t, holder.Release(ctx))
+	_, err = s.TryAcquire(ctx, key)
+	assert.Equal(t, ErrNoPermit, err)
+
+	var res result
+	select {
+	case res = <-waiter:
+	case <-time.After(time.Second):
+		t.Fatal("排队的没有拿到许可")
+	}
+	require.NoError(t, res.err)
+	require.NoError(t, res.p.Release(ctx))
+}
+
+func TestRedisSemaphore_AcquireTimeout(t *testing.T) {
+	s := NewRedisSemaphore(newTestRedis(t), 1).PollInterval(time.Millisecond * 10)
+	ctx := context.Background()
+	key := "semaphore:" + t.Name()
+
+	holder, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	_, err = s.Acquire(tctx, key)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 超时之后不再排队，不会挡住后来的
+	n, err := s.cmd.ZCard(ctx, key+":queue").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	require.NoError(t, holder.Release(ctx))
+	p, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	require.NoError(t, p.Release(ctx))
+}
+
+func TestRedisSemaphore_QueueTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	s := NewRedisSemaphore(client, 1).Lease(time.Second).PollInterval(time.Millisecond * 10)
+	ctx := context.Background()
+	key := "semaphore:" + t.Name()
+
+	holder, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_, _ = s.Acquire(wctx, key)
+	}()
+	require.Eventually(t, func() bool {
+		return mr.Exists(key + ":queue")
+	}, time.Second, time.Millisecond*5)
+
+	// 排队的时间远远超过了 key 的过期时间，每次检查都会续期，所以排队的 key 不会过期
+	for i := 0; i < 5; i++ {
+		mr.FastForward(time.Millisecond * 500)
+		for _, k := range []string{key + ":queue", key + ":alive", key + ":seq"} {
+			assert.True(t, mr.Exists(k), k)
+		}
+		require.NoError(t, holder.Refresh(ctx))
+		time.Sleep(time.Millisecond * 30)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	s := NewRedisSemaphore(newTestRedis(t), 1).Lease(time.Millisecond * 150)
+	ctx := context.Background()
+	key := "semaphore:" + t.Name()
+
+	p, err := s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	stop := KeepAlive(p, func(err error) {
+		t.Error(err)
+	})
+	// 超过了租约也不会被别人拿走
+	time.Sleep(time.Millisecond * 400)
+	_, err = s.TryAcquire(ctx, key)
+	assert.Equal(t, ErrNoPermit, err)
+	stop()
+	require.NoError(t, p.Release(ctx))
+
+	// 许可丢失之后不再续约
+	p, err = s.TryAcquire(ctx, key)
+	require.NoError(t, err)
+	require.NoError(t, p.Release(ctx))
+	lost := make(chan error, 1)
+	stop = KeepAlive(p, func(err error) {
+		lost <- err
+	})
+	defer stop()
+	select {
+	case err = <-lost:
+		assert.Equal(t, ErrPermitLost, err)
+	case <-time.After(time.Second):
+		t.Fatal("没有发现许可丢失了")
+	}
+}
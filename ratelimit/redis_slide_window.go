@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -17,6 +18,8 @@ type RedisSlidingWindowLimiter struct {
 	interval time.Duration
 	// 阈值
 	rate int
+	// 测试的时候可以替换
+	now func() time.Time
 }
 
 func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) Limiter {
@@ -24,10 +27,21 @@ func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rat
 		cmd:      cmd,
 		interval: interval,
 		rate:     rate,
+		now:      time.Now,
 	}
 }
 
 func (r *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(ctx, luaSlidingWindow, []string{key},
-		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli()).Bool()
+	d, err := r.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (r *RedisSlidingWindowLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	now := r.now()
+	res, err := r.cmd.Eval(ctx, luaSlidingWindow, []string{key},
+		r.interval.Milliseconds(), r.rate, now.UnixMilli(), uuid.NewString()).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return decisionFromScript(res, r.rate, time.Millisecond, now)
 }
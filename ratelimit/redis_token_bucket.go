@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 基于 Redis 的令牌桶
+// 每个 key 只存剩余令牌数和上一次补充的时间，允许最多 capacity 个请求的突发
+type RedisTokenBucketLimiter struct {
+	cmd redis.Cmdable
+	// 桶的容量
+	capacity int
+	// 每 interval 补充 rate 个令牌
+	interval time.Duration
+	rate     int
+	// 测试的时候可以替换
+	now func() time.Time
+}
+
+// NewRedisTokenBucketLimiter 桶的容量是 capacity，每 interval 补充 rate 个令牌
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, capacity int, interval time.Duration, rate int) Limiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		capacity: capacity,
+		interval: interval,
+		rate:     rate,
+		now:      time.Now,
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := r.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (r *RedisTokenBucketLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	now := r.now()
+	// 换算成每微秒补充多少个令牌
+	perMicro := float64(r.rate) / float64(r.interval.Microseconds())
+	res, err := r.cmd.Eval(ctx, luaTokenBucket, []string{key},
+		r.capacity, perMicro, now.UnixMicro()).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return decisionFromScript(res, r.capacity, time.Microsecond, now)
+}
@@ -0,0 +1,240 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 算法的名字
+const (
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmGCRA          = "gcra"
+)
+
+// KeyRule 按照 key 匹配的限流规则
+type KeyRule struct {
+	// 规则的名字，需要唯一
+	Name string
+	// 多条规则都匹配的时候 Priority 大的生效，一样大的按照名字排序，第一条生效。
+	// 具体的规则和兜底的规则同时存在的时候，需要给具体的规则设置更大的 Priority
+	Priority int
+	// path.Match 的模式，例如 ip-limiter:*、/user.v1.UserService/*
+	// 注意 * 和 ? 不匹配 /，/user.v1.UserService/* 只匹配这个服务的方法，
+	// ip-limiter:* 也匹配不到 ip-limiter:a/b 这种带 / 的 key，需要写成 ip-limiter:*/*
+	Pattern   string
+	Algorithm string
+	// Window 内最多 Rate 个请求
+	Window time.Duration
+	Rate   int
+	// 令牌桶的容量、GCRA 的突发请求数，0 代表等于 Rate
+	Burst int
+}
+
+// Validate 检查规则是否合法
+func (r KeyRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("ratelimit: rule name is required")
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("ratelimit: rule %s: pattern is required", r.Name)
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return fmt.Errorf("ratelimit: rule %s: %w", r.Name, err)
+	}
+	if r.Window <= 0 || r.Rate <= 0 || r.Burst < 0 {
+		return fmt.Errorf("ratelimit: rule %s: window and rate must be positive", r.Name)
+	}
+	return nil
+}
+
+// LimiterFactory 按照规则创建限流器，不支持的算法返回 error
+type LimiterFactory func(rule KeyRule) (Limiter, error)
+
+// NewRedisLimiterFactory 支持 sliding_window、token_bucket、gcra 三种算法
+func NewRedisLimiterFactory(cmd redis.Cmdable) LimiterFactory {
+	return func(rule KeyRule) (Limiter, error) {
+		burst := rule.Burst
+		if burst == 0 {
+			burst = rule.Rate
+		}
+		switch rule.Algorithm {
+		case AlgorithmSlidingWindow:
+			return NewRedisSlidingWindowLimiter(cmd, rule.Window, rule.Rate), nil
+		case AlgorithmTokenBucket:
+			return NewRedisTokenBucketLimiter(cmd, burst, rule.Window, rule.Rate), nil
+		case AlgorithmGCRA:
+			return NewRedisGCRALimiter(cmd, rule.Window, rule.Rate, burst), nil
+		default:
+			return nil, fmt.Errorf("ratelimit: rule %s: unknown algorithm %q", rule.Name, rule.Algorithm)
+		}
+	}
+}
+
+// RuleSet 一个版本的规则，创建之后只读
+type RuleSet struct {
+	// 版本号，从 etcd 加载的是 etcd 的 revision
+	Version int64
+	entries []ruleEntry
+}
+
+type ruleEntry struct {
+	rule    KeyRule
+	limiter Limiter
+}
+
+// Rules 按照匹配顺序返回所有的规则，Priority 从大到小，一样大的按照名字排序
+func (s *RuleSet) Rules() []KeyRule {
+	res := make([]KeyRule, 0, len(s.entries))
+	for _, e := range s.entries {
+		res = append(res, e.rule)
+	}
+	return res
+}
+
+func (s *RuleSet) match(key string) (ruleEntry, bool) {
+	for _, e := range s.entries {
+		if ok, _ := path.Match(e.rule.Pattern, key); ok {
+			return e, true
+		}
+	}
+	return ruleEntry{}, false
+}
+
+// Registry 可以在运行时替换规则的限流器，按照 key 找到第一条匹配的规则，交给这条规则的限流器判定，
+// 没有匹配的规则就放行。规则按照 Priority 从大到小匹配，Priority 一样的按照名字排序，
+// 比如 /user.v1.UserService/Login 和 /user.v1.UserService/* 都匹配的时候，
+// 要让前者生效需要给它设置更大的 Priority，不能依赖名字。实现了 DecisionLimiter，直接交给 gin 中间件和 gRPC 拦截器使用，
+// 规则替换是原子的，正在判定的请求用的还是旧的规则。
+// 新的规则不合法的时候整个版本都不会生效，继续使用上一个合法的版本。
+// 限流器用的 key 是 规则名:算法:原始的 key，修改了算法也不会读到另一种算法的数据
+type Registry struct {
+	factory LimiterFactory
+	// 保护更新，判定的时候不需要加锁
+	mu      sync.Mutex
+	active  atomic.Pointer[RuleSet]
+	lastErr atomic.Pointer[error]
+	// 每次加载之后调用，err 不为空代表这个版本没有生效
+	onReload func(version int64, err error)
+}
+
+// NewRegistry initial 是默认的规则，在加载到合法的规则之前使用
+func NewRegistry(factory LimiterFactory, initial ...KeyRule) (*Registry, error) {
+	r := &Registry{factory: factory}
+	r.active.Store(&RuleSet{})
+	if err := r.Update(0, initial); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload 每次加载规则之后调用，可以用来打日志或者告警，需要在监听之前设置
+// 调用的时候持有更新的锁，不会并发调用，fn 里面不能再调用 Update
+func (r *Registry) OnReload(fn func(version int64, err error)) *Registry {
+	r.onReload = fn
+	return r
+}
+
+// Update 校验并替换所有的规则，不合法的时候返回 error，继续使用原来的规则
+// 没有变化的规则会复用原来的限流器
+func (r *Registry) Update(version int64, rules []KeyRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload(version, rules)
+}
+
+// reload 调用方需要持有 mu
+func (r *Registry) reload(version int64, rules []KeyRule) error {
+	if err := r.update(version, rules); err != nil {
+		r.reject(version, err)
+		return err
+	}
+	r.lastErr.Store(nil)
+	if r.onReload != nil {
+		r.onReload(version, nil)
+	}
+	return nil
+}
+
+// reject 记录没有生效的版本，调用方需要持有 mu
+func (r *Registry) reject(version int64, err error) {
+	r.lastErr.Store(&err)
+	if r.onReload != nil {
+		r.onReload(version, err)
+	}
+}
+
+func (r *Registry) update(version int64, rules []KeyRule) error {
+	old := r.active.Load()
+	prev := make(map[string]ruleEntry, len(old.entries))
+	for _, e := range old.entries {
+		prev[e.rule.Name] = e
+	}
+	entries := make([]ruleEntry, 0, len(rules))
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("ratelimit: duplicate rule %s", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+		if e, ok := prev[rule.Name]; ok && e.rule == rule {
+			entries = append(entries, e)
+			continue
+		}
+		l, err := r.factory(rule)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ruleEntry{rule: rule, limiter: l})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].rule.Priority != entries[j].rule.Priority {
+			return entries[i].rule.Priority > entries[j].rule.Priority
+		}
+		return entries[i].rule.Name < entries[j].rule.Name
+	})
+	r.active.Store(&RuleSet{Version: version, entries: entries})
+	return nil
+}
+
+// Active 返回当前生效的规则
+func (r *Registry) Active() *RuleSet {
+	return r.active.Load()
+}
+
+// Version 当前生效的规则的版本
+func (r *Registry) Version() int64 {
+	return r.active.Load().Version
+}
+
+// LastError 最近一次加载的错误，最近一次加载成功的话返回 nil
+func (r *Registry) LastError() error {
+	if err := r.lastErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (r *Registry) Limit(ctx context.Context, key string) (bool, error) {
+	d, err := r.Decide(ctx, key)
+	return !d.Allowed, err
+}
+
+func (r *Registry) Decide(ctx context.Context, key string) (Decision, error) {
+	e, ok := r.active.Load().match(key)
+	if !ok {
+		return Decision{Allowed: true}, nil
+	}
+	return Decide(ctx, e.limiter, e.rule.Name+":"+e.rule.Algorithm+":"+key)
+}
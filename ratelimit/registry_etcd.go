@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ErrWatchClosed ctx 没有结束，etcd 的监听就被关闭了，比如 etcd 的客户端被关闭了
+var ErrWatchClosed = errors.New("ratelimit: etcd watch channel closed")
+
+// EtcdClient 监听规则需要用到的 etcd 能力，*clientv3.Client 就实现了这个接口
+type EtcdClient interface {
+	clientv3.KV
+	clientv3.Watcher
+}
+
+// etcdRule etcd 里保存的规则，例如
+//
+//	{"pattern":"ip-limiter:*","algorithm":"sliding_window","window":"1s","rate":100,"priority":10}
+type etcdRule struct {
+	Pattern   string `json:"pattern"`
+	Priority  int    `json:"priority"`
+	Algorithm string `json:"algorithm"`
+	Window    string `json:"window"`
+	Rate      int    `json:"rate"`
+	Burst     int    `json:"burst"`
+}
+
+// WatchEtcd 从 etcd 的 prefix 下加载规则并持续监听变化，阻塞直到 ctx 结束或者监听出错
+// key 去掉 prefix 之后就是规则的名字，value 是 JSON。
+// 每次变化之后用 prefix 下的所有规则生成一个新的版本，版本号是 etcd 的 revision，
+// 有任何一条规则不合法的话这个版本不会生效，继续使用上一个合法的版本，直到规则被修正。
+// 返回 error 之后已经生效的规则仍然可以使用，调用方可以重新监听。
+// ctx 结束的时候返回 ctx.Err()，监听被关闭的时候返回 ErrWatchClosed
+func (r *Registry) WatchEtcd(ctx context.Context, client EtcdClient, prefix string) error {
+	resp, err := client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	raw := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		raw[strings.TrimPrefix(string(kv.Key), prefix)] = kv.Value
+	}
+	r.applyEtcd(resp.Header.Revision, raw)
+	// 从 Get 的版本之后开始监听，避免漏掉中间的修改
+	ch := client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range ch {
+		if err = wresp.Err(); err != nil {
+			return err
+		}
+		if len(wresp.Events) == 0 {
+			continue
+		}
+		for _, ev := range wresp.Events {
+			name := strings.TrimPrefix(string(ev.Kv.Key), prefix)
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				raw[name] = ev.Kv.Value
+			case clientv3.EventTypeDelete:
+				delete(raw, name)
+			}
+		}
+		r.applyEtcd(wresp.Header.Revision, raw)
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWatchClosed
+}
+
+// applyEtcd raw 是 prefix 下所有的规则，不合法的话这个版本不生效
+// 跟 Update 一样持有 mu，OnReload 不会并发调用
+func (r *Registry) applyEtcd(version int64, raw map[string][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rules := make([]KeyRule, 0, len(raw))
+	for name, val := range raw {
+		rule, err := parseEtcdRule(name, val)
+		if err != nil {
+			r.reject(version, err)
+			return
+		}
+		rules = append(rules, rule)
+	}
+	_ = r.reload(version, rules)
+}
+
+func parseEtcdRule(name string, val []byte) (KeyRule, error) {
+	var cfg etcdRule
+	if err := json.Unmarshal(val, &cfg); err != nil {
+		return KeyRule{}, fmt.Errorf("ratelimit: rule %s: %w", name, err)
+	}
+	window, err := time.ParseDuration(cfg.Window)
+	if err != nil {
+		return KeyRule{}, fmt.Errorf("ratelimit: rule %s: %w", name, err)
+	}
+	return KeyRule{
+		Name:      name,
+		Pattern:   cfg.Pattern,
+		Priority:  cfg.Priority,
+		Algorithm: cfg.Algorithm,
+		Window:    window,
+		Rate:      cfg.Rate,
+		Burst:     cfg.Burst,
+	}, nil
+}
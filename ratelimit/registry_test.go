@@ -0,0 +1,340 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestRegistry(t *testing.T) {
+	cmd := newTestRedis(t)
+	r, err := NewRegistry(NewRedisLimiterFactory(cmd),
+		KeyRule{Name: "ip", Pattern: "ip-limiter:*", Algorithm: AlgorithmSlidingWindow, Window: time.Minute, Rate: 1})
+	require.NoError(t, err)
+	ctx := context.Background()
+	key := "ip-limiter:" + t.Name()
+
+	d, err := r.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	d, err = r.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	// 没有匹配的规则就放行
+	d, err = r.Decide(ctx, "user-limiter:1")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	// 不合法的规则不会生效
+	err = r.Update(1, []KeyRule{{Name: "ip", Pattern: "ip-limiter:*", Algorithm: "leaky_bucket", Window: time.Minute, Rate: 1}})
+	assert.Error(t, err)
+	assert.Equal(t, err, r.LastError())
+	assert.Equal(t, int64(0), r.Version())
+
+	// 换一种算法，阈值调大之后放行
+	require.NoError(t, r.Update(2, []KeyRule{
+		{Name: "ip", Pattern: "ip-limiter:*", Algorithm: AlgorithmGCRA, Window: time.Minute, Rate: 10},
+	}))
+	assert.NoError(t, r.LastError())
+	assert.Equal(t, int64(2), r.Version())
+	d, err = r.Decide(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 10, d.Limit)
+}
+
+func TestRegistry_Priority(t *testing.T) {
+	// 兜底的规则名字排在前面，按照 Priority 匹配的话还是具体的规则生效
+	r, err := NewRegistry(NewRedisLimiterFactory(newTestRedis(t)),
+		KeyRule{Name: "a-all", Pattern: "/user.v1.UserService/*", Algorithm: AlgorithmSlidingWindow, Window: time.Minute, Rate: 100},
+		KeyRule{Name: "z-login", Pattern: "/user.v1.UserService/Login", Priority: 10,
+			Algorithm: AlgorithmSlidingWindow, Window: time.Minute, Rate: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z-login", "a-all"}, ruleNames(r.Active().Rules()))
+	ctx := context.Background()
+
+	d, err := r.Decide(ctx, "/user.v1.UserService/Login")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 1, d.Limit)
+	d, err = r.Decide(ctx, "/user.v1.UserService/Login")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	// 其它方法走兜底的规则
+	d, err = r.Decide(ctx, "/user.v1.UserService/Profile")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 100, d.Limit)
+}
+
+func TestKeyRule_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rule    KeyRule
+		wantErr bool
+	}{
+		{
+			name: "合法",
+			rule: KeyRule{Name: "ip", Pattern: "ip-limiter:*", Algorithm: AlgorithmTokenBucket, Window: time.Second, Rate: 10},
+		},
+		{
+			name:    "没有名字",
+			rule:    KeyRule{Pattern: "*", Window: time.Second, Rate: 10},
+			wantErr: true,
+		},
+		{
+			name:    "模式不合法",
+			rule:    KeyRule{Name: "ip", Pattern: "ip-limiter:[", Window: time.Second, Rate: 10},
+			wantErr: true,
+		},
+		{
+			name:    "阈值不合法",
+			rule:    KeyRule{Name: "ip", Pattern: "*", Window: time.Second},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			assert.Equal(t, tc.wantErr, err != nil, err)
+		})
+	}
+}
+
+// fakeEtcd 只支持按照前缀读取和监听，
+// put 写入的修改会记下来，Watch 带了 WithRev 的时候先重放这个版本之后的修改，再转发 ch 里的事件
+type fakeEtcd struct {
+	clientv3.KV
+	clientv3.Watcher
+	mu      sync.Mutex
+	rev     int64
+	kvs     []*mvccpb.KeyValue
+	history []clientv3.WatchResponse
+	ch      chan clientv3.WatchResponse
+	// Get 返回之前调用，用来模拟 Get 和 Watch 之间的修改
+	afterGet func()
+}
+
+func (f *fakeEtcd) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	f.mu.Lock()
+	resp := &clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: f.rev},
+		Kvs:    append([]*mvccpb.KeyValue(nil), f.kvs...),
+	}
+	f.mu.Unlock()
+	if f.afterGet != nil {
+		f.afterGet()
+	}
+	return resp, nil
+}
+
+func (f *fakeEtcd) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	rev := clientv3.OpGet(key, opts...).Rev()
+	f.mu.Lock()
+	var replay []clientv3.WatchResponse
+	for _, resp := range f.history {
+		if rev > 0 && resp.Header.Revision >= rev {
+			replay = append(replay, resp)
+		}
+	}
+	f.mu.Unlock()
+	ch := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(ch)
+		for _, resp := range replay {
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+		for resp := range f.ch {
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// put 写入一个 key，版本号加一
+func (f *fakeEtcd) put(key, val string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rev++
+	kv := &mvccpb.KeyValue{Key: []byte(key), Value: []byte(val), ModRevision: f.rev}
+	f.kvs = append(f.kvs, kv)
+	f.history = append(f.history, clientv3.WatchResponse{
+		Header: etcdserverpb.ResponseHeader{Revision: f.rev},
+		Events: []*clientv3.Event{{Type: clientv3.EventTypePut, Kv: kv}},
+	})
+}
+
+func TestRegistry_WatchEtcd(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reloads []int64
+	)
+	r, err := NewRegistry(NewRedisLimiterFactory(newTestRedis(t)))
+	require.NoError(t, err)
+	r.OnReload(func(version int64, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			reloads = append(reloads, version)
+		}
+	})
+	client := &fakeEtcd{
+		kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/ratelimit/rules/ip"), Value: []byte(`{"pattern":"ip-limiter:*","algorithm":"sliding_window","window":"1m","rate":100}`)},
+		},
+		rev: 10,
+		ch:  make(chan clientv3.WatchResponse, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchEtcd(ctx, client, "/ratelimit/rules/")
+	}()
+	require.Eventually(t, func() bool {
+		return r.Version() == 10
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []KeyRule{
+		{Name: "ip", Pattern: "ip-limiter:*", Algorithm: AlgorithmSlidingWindow, Window: time.Minute, Rate: 100},
+	}, r.Active().Rules())
+	ip := r.Active().entries[0].limiter
+
+	// 有一条规则不合法，整个版本都不生效
+	client.ch <- clientv3.WatchResponse{
+		Header: etcdserverpb.ResponseHeader{Revision: 11},
+		Events: []*clientv3.Event{
+			{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{
+				Key: []byte("/ratelimit/rules/user"), Value: []byte(`{"pattern":"user-limiter:*","algorithm":"gcra","window":"1m","rate":0}`)}},
+		},
+	}
+	require.Eventually(t, func() bool {
+		return r.LastError() != nil
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, int64(10), r.Version())
+
+	// 修正之后生效，没有变化的规则复用原来的限流器
+	client.ch <- clientv3.WatchResponse{
+		Header: etcdserverpb.ResponseHeader{Revision: 12},
+		Events: []*clientv3.Event{
+			{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{
+				Key: []byte("/ratelimit/rules/user"), Value: []byte(`{"pattern":"user-limiter:*","algorithm":"gcra","window":"1m","rate":10,"burst":5}`)}},
+		},
+	}
+	require.Eventually(t, func() bool {
+		return r.Version() == 12
+	}, time.Second, time.Millisecond*10)
+	assert.NoError(t, r.LastError())
+	require.Len(t, r.Active().entries, 2)
+	assert.Same(t, ip, r.Active().entries[0].limiter)
+
+	// 删除规则
+	client.ch <- clientv3.WatchResponse{
+		Header: etcdserverpb.ResponseHeader{Revision: 13},
+		Events: []*clientv3.Event{
+			{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: []byte("/ratelimit/rules/ip")}},
+		},
+	}
+	require.Eventually(t, func() bool {
+		return r.Version() == 13
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []string{"user"}, ruleNames(r.Active().Rules()))
+
+	cancel()
+	close(client.ch)
+	assert.ErrorIs(t, <-done, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{10, 12, 13}, reloads)
+}
+
+func ruleNames(rules []KeyRule) []string {
+	res := make([]string, 0, len(rules))
+	for _, r := range rules {
+		res = append(res, r.Name)
+	}
+	return res
+}
+
+func TestRegistry_WatchEtcdHandoff(t *testing.T) {
+	r, err := NewRegistry(NewRedisLimiterFactory(newTestRedis(t)))
+	require.NoError(t, err)
+	client := &fakeEtcd{ch: make(chan clientv3.WatchResponse)}
+	client.put("/ratelimit/rules/ip", `{"pattern":"ip-limiter:*","algorithm":"sliding_window","window":"1m","rate":100}`)
+	// Get 之后、Watch 之前新增了一条规则，从 Get 的版本之后开始监听才不会漏掉
+	client.afterGet = func() {
+		client.put("/ratelimit/rules/user", `{"pattern":"user-limiter:*","algorithm":"gcra","window":"1m","rate":10}`)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchEtcd(ctx, client, "/ratelimit/rules/")
+	}()
+	require.Eventually(t, func() bool {
+		return r.Version() == 2
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []string{"ip", "user"}, ruleNames(r.Active().Rules()))
+
+	cancel()
+	close(client.ch)
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRegistry_WatchEtcdClosed(t *testing.T) {
+	r, err := NewRegistry(NewRedisLimiterFactory(newTestRedis(t)))
+	require.NoError(t, err)
+	client := &fakeEtcd{ch: make(chan clientv3.WatchResponse)}
+	close(client.ch)
+	assert.ErrorIs(t, r.WatchEtcd(context.Background(), client, "/ratelimit/rules/"), ErrWatchClosed)
+}
+
+func TestRegistry_ConcurrentReload(t *testing.T) {
+	// OnReload 不会并发调用，所以这里不加锁，go test -race 可以发现并发调用
+	var reloads int
+	r, err := NewRegistry(NewRedisLimiterFactory(newTestRedis(t)))
+	require.NoError(t, err)
+	r.OnReload(func(version int64, err error) {
+		reloads++
+	})
+	client := &fakeEtcd{ch: make(chan clientv3.WatchResponse)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchEtcd(ctx, client, "/ratelimit/rules/")
+	}()
+	go func() {
+		for i := int64(11); i < 31; i++ {
+			client.ch <- clientv3.WatchResponse{
+				Header: etcdserverpb.ResponseHeader{Revision: i},
+				Events: []*clientv3.Event{
+					{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{
+						Key: []byte("/ratelimit/rules/bad"), Value: []byte(`{`)}},
+				},
+			}
+		}
+		cancel()
+		close(client.ch)
+	}()
+	for version := int64(100); ; version++ {
+		select {
+		case err = <-done:
+			assert.ErrorIs(t, err, context.Canceled)
+			return
+		default:
+			_ = r.Update(version, nil)
+		}
+	}
+}
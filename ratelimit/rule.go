@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Dimension 限流的维度
+type Dimension string
+
+const (
+	// DimGlobal 所有请求共用一个计数
+	DimGlobal Dimension = "global"
+	// DimRoute 每个路由单独计数，HTTP 是方法加路由，gRPC 是完整的方法名
+	DimRoute Dimension = "route"
+	// DimUser 每个用户单独计数，没有登录的请求不受这条规则限制
+	DimUser Dimension = "user"
+	// DimIP 每个 IP 单独计数
+	DimIP Dimension = "ip"
+	// DimHeader 按照请求头（gRPC 是 metadata）的值单独计数，例如 app key
+	DimHeader Dimension = "header"
+)
+
+// Rule 一条限流规则
+type Rule struct {
+	// 规则的名字，需要唯一，会作为 key 的一部分，也会在触发限流的时候返回
+	Name      string
+	Dimension Dimension
+	// DimHeader 使用的请求头
+	Header string
+	// 只对路由前缀是 Match 的请求生效，为空代表所有请求
+	Match string
+	// Interval 内最多 Rate 个请求
+	Interval time.Duration
+	Rate     int
+}
+
+// Validate 检查规则是否合法
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("ratelimit: rule name is required")
+	}
+	if r.Interval <= 0 || r.Rate <= 0 {
+		return fmt.Errorf("ratelimit: rule %s: interval and rate must be positive", r.Name)
+	}
+	switch r.Dimension {
+	case DimGlobal, DimRoute, DimUser, DimIP:
+	case DimHeader:
+		if r.Header == "" {
+			return fmt.Errorf("ratelimit: rule %s: header is required", r.Name)
+		}
+	default:
+		return fmt.Errorf("ratelimit: rule %s: unknown dimension %q", r.Name, r.Dimension)
+	}
+	return nil
+}
+
+// ValidateRules 检查每一条规则，以及名字是否重复
+func ValidateRules(rules []Rule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("ratelimit: duplicate rule %s", r.Name)
+		}
+		names[r.Name] = struct{}{}
+	}
+	return nil
+}
+
+// value 返回请求在这条规则上的计数维度，false 代表规则不适用于这个请求
+func (r Rule) value(req Request) (string, bool) {
+	if r.Match != "" && !strings.HasPrefix(req.Route, r.Match) {
+		return "", false
+	}
+	var val string
+	switch r.Dimension {
+	case DimGlobal:
+		return "", true
+	case DimRoute:
+		val = req.Route
+		if req.Method != "" {
+			val = req.Method + " " + req.Route
+		}
+	case DimUser:
+		val = req.UserID
+	case DimIP:
+		val = req.IP
+	case DimHeader:
+		if req.Header != nil {
+			val = req.Header(r.Header)
+		}
+	}
+	return val, val != ""
+}
+
+// Request 一次请求在各个维度上的值，由 gin 中间件或者 gRPC 拦截器填充
+type Request struct {
+	// HTTP 方法，gRPC 为空
+	Method string
+	// HTTP 的路由，例如 /users/:id，gRPC 的完整方法名，例如 /user.v1.UserService/GetByID
+	Route  string
+	UserID string
+	IP     string
+	// 读取请求头或者 metadata
+	Header func(name string) string
+}
+
+// RuleDecision 多条规则的判定结果
+// 放行的时候 Decision 是剩余额度最少的那条规则的，限流的时候是触发限流的规则的
+type RuleDecision struct {
+	Decision
+	// 触发限流的规则，放行的时候是剩余额度最少的规则，没有规则适用的时候为空
+	Rule string
+}
+
+// RuleLimiter 按照多条规则限流，所有的规则都通过才放行
+type RuleLimiter interface {
+	DecideRequest(ctx context.Context, req Request) (RuleDecision, error)
+}
@@ -0,0 +1,48 @@
+-- 多条规则一起判定，每条规则是一个 GCRA，只存一个理论到达时间（TAT）
+-- KEYS 是每条规则的 key
+-- ARGV[1] 是当前时间，微秒，之后每条规则两个参数：请求之间的间隔（微秒）、允许的突发请求数
+local now = tonumber(ARGV[1])
+local n = #KEYS
+if not now or #ARGV ~= n * 2 + 1 then
+    return redis.error_reply("invalid parameters")
+end
+
+local tats = {}
+-- 第一遍只检查，有一条规则不通过就不消耗任何规则的额度
+-- 有多条规则不通过的时候，返回需要等最久的那条
+local tripped, retry = 0, 0
+for i = 1, n do
+    local emission = tonumber(ARGV[i * 2])
+    local burst = tonumber(ARGV[i * 2 + 1])
+    local tat = tonumber(redis.call('GET', KEYS[i]))
+    if not tat or tat < now then
+        tat = now
+    end
+    tats[i] = tat
+    local allowAt = tat + emission - emission * burst
+    if allowAt > now and allowAt - now > retry then
+        tripped = i
+        retry = allowAt - now
+    end
+end
+
+-- 返回 是否限流、是哪条规则（从 1 开始）、剩余额度、多少微秒之后额度完全恢复、多少微秒之后可以重试
+if tripped > 0 then
+    return {1, tripped, 0, tats[tripped] - now, retry}
+end
+
+-- 第二遍消耗额度，顺便找出剩余额度最少的规则
+local minIdx, minRemaining, reset = 0, -1, 0
+for i = 1, n do
+    local emission = tonumber(ARGV[i * 2])
+    local burst = tonumber(ARGV[i * 2 + 1])
+    local newTat = tats[i] + emission
+    redis.call('SET', KEYS[i], string.format('%d', newTat), 'PX', math.ceil((newTat - now) / 1000))
+    local remaining = math.floor((now - (newTat - emission * burst)) / emission)
+    if minRemaining < 0 or remaining < minRemaining then
+        minIdx = i
+        minRemaining = remaining
+        reset = newTat - now
+    end
+end
+return {0, minIdx, minRemaining, reset, 0}
@@ -0,0 +1,66 @@
+-- KEYS[1] 持有者，zset，score 是租约到期的时间
+-- KEYS[2] 排队的，zset，score 是排队的序号
+-- KEYS[3] 排队的心跳，zset，score 是多久没有心跳就移出队列
+-- KEYS[4] 排队的序号
+-- ARGV: 许可数、租约（毫秒）、token、是否排队、排队心跳的超时时间（毫秒）
+local holders, queue, alive, seq = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+local permits = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local token = ARGV[3]
+local wait = ARGV[4] == '1'
+local aliveTTL = tonumber(ARGV[5])
+-- 用 Redis 的时间，不依赖各个客户端的时钟是一致的
+-- Redis 5 之前要先打开按命令复制，才能在 TIME 之后写数据
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+-- 清理租约到期的持有者，以及不再等待的排队者（比如进程挂了）
+redis.call('ZREMRANGEBYSCORE', holders, '-inf', now)
+local dead = redis.call('ZRANGEBYSCORE', alive, '-inf', now)
+if #dead > 0 then
+    redis.call('ZREM', queue, unpack(dead))
+    redis.call('ZREM', alive, unpack(dead))
+end
+
+local ttl = math.max(lease, aliveTTL)
+-- 排队的 key 跟着每一次检查续期，排得久的时候不会过期
+local function keepQueue()
+    redis.call('PEXPIRE', queue, ttl)
+    redis.call('PEXPIRE', alive, ttl)
+    redis.call('PEXPIRE', seq, ttl)
+end
+local function acquire()
+    redis.call('ZREM', queue, token)
+    redis.call('ZREM', alive, token)
+    redis.call('ZADD', holders, now + lease, token)
+    redis.call('PEXPIRE', holders, ttl)
+    return 1
+end
+
+if redis.call('ZSCORE', holders, token) then
+    return 1
+end
+
+local free = permits - redis.call('ZCARD', holders)
+local rank = redis.call('ZRANK', queue, token)
+if rank then
+    -- 排在前面 free 个的可以拿到许可
+    if rank < free then
+        return acquire()
+    end
+    redis.call('ZADD', alive, now + aliveTTL, token)
+    keepQueue()
+    return 0
+end
+
+-- 不插队，空闲的许可比排队的人多才能直接拿
+if free > redis.call('ZCARD', queue) then
+    return acquire()
+end
+if wait then
+    redis.call('ZADD', queue, redis.call('INCR', seq), token)
+    redis.call('ZADD', alive, now + aliveTTL, token)
+    keepQueue()
+end
+return 0
@@ -0,0 +1,18 @@
+-- KEYS[1] 持有者
+-- ARGV: 租约（毫秒）、token
+-- 返回 1 代表续约成功，0 代表许可已经过期或者被释放了
+local lease = tonumber(ARGV[1])
+local token = ARGV[2]
+-- 跟获取许可的时候一样用 Redis 的时间
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local expire = tonumber(redis.call('ZSCORE', KEYS[1], token))
+if not expire or expire <= now then
+    return 0
+end
+redis.call('ZADD', KEYS[1], now + lease, token)
+if redis.call('PTTL', KEYS[1]) < lease then
+    redis.call('PEXPIRE', KEYS[1], lease)
+end
+return 1
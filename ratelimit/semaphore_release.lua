@@ -0,0 +1,16 @@
+-- KEYS[1] 持有者，KEYS[2] 排队的，KEYS[3] 排队的心跳
+-- ARGV: token
+-- 返回 1 代表释放了没有过期的许可，0 代表许可已经过期或者被释放了
+local token = ARGV[1]
+-- 跟获取许可的时候一样用 Redis 的时间
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call('ZREM', KEYS[2], token)
+redis.call('ZREM', KEYS[3], token)
+local expire = tonumber(redis.call('ZSCORE', KEYS[1], token))
+redis.call('ZREM', KEYS[1], token)
+if expire and expire > now then
+    return 1
+end
+return 0
@@ -9,23 +9,31 @@ local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
--- 窗口的起始时间
-local min = now - window
+-- 每个请求唯一的 id，同一毫秒内的请求不会合并成一个 member
+local id = ARGV[4]
 
 -- 检查参数是否有效
-if not window or not threshold or not now then
-    return "error: invalid parameters"
+if not window or not threshold or not now or not id then
+    return redis.error_reply("invalid parameters")
 end
 
+-- 窗口的起始时间
+local min = now - window
+
 redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
+-- 返回 是否限流、剩余额度、多少毫秒之后额度完全恢复、多少毫秒之后可以重试
 if cnt >= threshold then
-    -- 执行限流
-    return "true"
+    -- 执行限流，最早的请求滑出窗口之后就可以重试了
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+    local retry = tonumber(oldest[2]) + window - now
+    local reset = tonumber(newest[2]) + window - now
+    return {1, 0, reset, retry}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    -- score 是 now，member 带上请求的 id，避免同一毫秒内的请求只算一个
+    redis.call('ZADD', key, now, now .. '-' .. id)
     redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    return {0, threshold - cnt - 1, window, 0}
+end
@@ -0,0 +1,39 @@
+-- 令牌桶，桶里面存剩余的令牌数和上一次补充令牌的时间
+local key = KEYS[1]
+-- 桶的容量，也就是允许的突发
+local capacity = tonumber(ARGV[1])
+-- 每微秒补充的令牌数
+local rate = tonumber(ARGV[2])
+-- 当前时间，微秒
+local now = tonumber(ARGV[3])
+
+if not capacity or not rate or not now then
+    return redis.error_reply("invalid parameters")
+end
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if not tokens or not ts then
+    tokens = capacity
+    ts = now
+end
+-- 不同机器的时钟可能不一致，时间不能倒退
+if now > ts then
+    tokens = math.min(capacity, tokens + (now - ts) * rate)
+    ts = now
+end
+
+local limited = tokens < 1
+if not limited then
+    tokens = tokens - 1
+end
+redis.call('HSET', key, 'tokens', tokens, 'ts', ts)
+-- 多少微秒之后桶会装满，装满之后和不存在是一样的，可以过期掉
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('PEXPIRE', key, math.ceil(reset / 1000) + 1)
+-- 返回 是否限流、剩余令牌数、多少微秒之后桶会装满、多少微秒之后可以重试
+if limited then
+    return {1, 0, reset, math.ceil((1 - tokens) / rate)}
+end
+return {0, math.floor(tokens), reset, 0}
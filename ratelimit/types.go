@@ -2,6 +2,8 @@ package ratelimit
 
 import (
 	"context"
+	"fmt"
+	"time"
 )
 
 //go:generate mockgen -source=./types.go -package=limitermocks -destination=./mocks/limiter.mock.go Limiter
@@ -9,3 +11,49 @@ type Limiter interface {
 	// Limit 限流
 	Limit(ctx context.Context, key string) (bool, error)
 }
+
+// Decision 一次限流判定的详细结果
+type Decision struct {
+	// 是否放行
+	Allowed bool
+	// 限额，例如窗口内的阈值、桶的容量
+	Limit int
+	// 这次判定之后还剩多少额度
+	Remaining int
+	// 额度完全恢复的时间
+	ResetAt time.Time
+	// 被限流的时候，至少要等多久再重试
+	RetryAfter time.Duration
+}
+
+// DecisionLimiter 能够返回限流详情的 Limiter
+type DecisionLimiter interface {
+	Limiter
+	// Decide 判定并且消耗一次额度，和 Limit 一样
+	Decide(ctx context.Context, key string) (Decision, error)
+}
+
+// Decide 限流器实现了 DecisionLimiter 就返回详细的结果，
+// 否则只有 Allowed 是有意义的
+func Decide(ctx context.Context, l Limiter, key string) (Decision, error) {
+	if dl, ok := l.(DecisionLimiter); ok {
+		return dl.Decide(ctx, key)
+	}
+	limited, err := l.Limit(ctx, key)
+	return Decision{Allowed: !limited}, err
+}
+
+// decisionFromScript 解析 lua 脚本返回的 是否限流、剩余额度、多久之后额度完全恢复、多久之后可以重试
+// unit 是脚本里面时间的单位
+func decisionFromScript(res []int64, limit int, unit time.Duration, now time.Time) (Decision, error) {
+	if len(res) != 4 {
+		return Decision{}, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+	return Decision{
+		Allowed:    res[0] == 0,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		ResetAt:    now.Add(time.Duration(res[2]) * unit),
+		RetryAfter: time.Duration(res[3]) * unit,
+	}, nil
+}
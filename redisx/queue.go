@@ -0,0 +1,245 @@
+package redisx
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kirby980/go-pkg"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed queue_dequeue.lua
+	luaQueueDequeue string
+	//go:embed queue_ack.lua
+	luaQueueAck string
+	//go:embed queue_nack.lua
+	luaQueueNack string
+)
+
+// ErrMessageExpired 消息的可见性超时已经过了，可能已经被重新投递给别的消费者
+var ErrMessageExpired = errors.New("message visibility timeout expired")
+
+// Queue 基于 redis 的分布式可靠队列，多个实例可以共享同一个队列
+// 取出的消息在可见性超时之内没有 Ack 就会被重新投递，
+// 投递次数超过上限之后进入死信列表
+type Queue[T any] struct {
+	cmd   redis.Cmdable
+	codec pkg.Codec[T]
+
+	readyKey      string
+	msgsKey       string
+	deliveriesKey string
+	processingKey string
+	deadKey       string
+
+	visibilityTimeout time.Duration
+	maxDeliveries     int
+	pollInterval      time.Duration
+}
+
+// NewQueue 创建队列，name 相同的队列共享数据
+func NewQueue[T any](cmd redis.Cmdable, name string, codec pkg.Codec[T]) *Queue[T] {
+	// 用 hash tag 保证在 redis cluster 下所有的 key 都在同一个 slot
+	prefix := fmt.Sprintf("queue:{%s}", name)
+	return &Queue[T]{
+		cmd:               cmd,
+		codec:             codec,
+		readyKey:          prefix + ":ready",
+		msgsKey:           prefix + ":msgs",
+		deliveriesKey:     prefix + ":deliveries",
+		processingKey:     prefix + ":processing",
+		deadKey:           prefix + ":dead",
+		visibilityTimeout: time.Second * 30,
+		maxDeliveries:     5,
+		pollInterval:      time.Millisecond * 100,
+	}
+}
+
+// VisibilityTimeout 设置可见性超时，取出的消息超过这个时间没有 Ack 就会被重新投递
+func (q *Queue[T]) VisibilityTimeout(timeout time.Duration) *Queue[T] {
+	q.visibilityTimeout = timeout
+	return q
+}
+
+// MaxDeliveries 设置最大投递次数，超过之后进入死信列表，0 代表不限制
+func (q *Queue[T]) MaxDeliveries(n int) *Queue[T] {
+	q.maxDeliveries = n
+	return q
+}
+
+// PollInterval 设置队列为空时 Dequeue 轮询的间隔
+func (q *Queue[T]) PollInterval(interval time.Duration) *Queue[T] {
+	q.pollInterval = interval
+	return q
+}
+
+// Message 从队列里取出的消息
+type Message[T any] struct {
+	ID    string
+	Value T
+	// 第几次投递，从 1 开始
+	Deliveries int
+	q          *Queue[T]
+}
+
+// Ack 确认消息已经处理完
+func (m *Message[T]) Ack(ctx context.Context) error {
+	return m.q.ack(ctx, m.ID, m.Deliveries)
+}
+
+// Nack 处理失败，消息马上重新投递，投递次数用完了就进入死信列表
+func (m *Message[T]) Nack(ctx context.Context) error {
+	return m.q.nack(ctx, m.ID, m.Deliveries)
+}
+
+// Enqueue 入队
+func (q *Queue[T]) Enqueue(ctx context.Context, v T) error {
+	data, err := q.codec.Encode(v)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+	id := uuid.NewString()
+	_, err = q.cmd.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.msgsKey, id, data)
+		pipe.RPush(ctx, q.readyKey, id)
+		return nil
+	})
+	return err
+}
+
+// Dequeue 取出队首消息，队列为空时阻塞直到有消息或者 ctx 结束
+func (q *Queue[T]) Dequeue(ctx context.Context) (*Message[T], error) {
+	// 轮询的时候复用同一个 timer
+	var timer *time.Timer
+	for {
+		msg, err := q.TryDequeue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return msg, nil
+		}
+		if timer == nil {
+			timer = time.NewTimer(q.pollInterval)
+			defer timer.Stop()
+		} else {
+			timer.Reset(q.pollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// TryDequeue 非阻塞地取出队首消息，队列为空时返回 nil
+// 可见性超时按照 redis 的时间计算，不依赖各个实例的时钟是一致的
+func (q *Queue[T]) TryDequeue(ctx context.Context) (*Message[T], error) {
+	res, err := q.cmd.Eval(ctx, luaQueueDequeue, q.keys(),
+		q.visibilityTimeout.Milliseconds(), q.maxDeliveries).Slice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("unexpected dequeue result: %v", res)
+	}
+	id, _ := res[0].(string)
+	payload, _ := res[1].(string)
+	cnt, _ := res[2].(int64)
+	v, err := q.codec.Decode([]byte(payload))
+	if err != nil {
+		return nil, fmt.Errorf("decode message %s: %w", id, err)
+	}
+	return &Message[T]{ID: id, Value: v, Deliveries: int(cnt), q: q}, nil
+}
+
+// Peek 查看队首消息但是不取出，队列为空时返回 false
+func (q *Queue[T]) Peek(ctx context.Context) (T, bool, error) {
+	id, err := q.cmd.LIndex(ctx, q.readyKey, 0).Result()
+	if err == redis.Nil {
+		return *new(T), false, nil
+	}
+	if err != nil {
+		return *new(T), false, err
+	}
+	data, err := q.cmd.HGet(ctx, q.msgsKey, id).Bytes()
+	if err == redis.Nil {
+		// 刚好被别人取走并且 ack 了
+		return *new(T), false, nil
+	}
+	if err != nil {
+		return *new(T), false, err
+	}
+	v, err := q.codec.Decode(data)
+	if err != nil {
+		return *new(T), false, fmt.Errorf("decode message %s: %w", id, err)
+	}
+	return v, true, nil
+}
+
+// Len 返回等待投递的消息数量，不包含已经取出但是还没有 ack 的消息
+func (q *Queue[T]) Len(ctx context.Context) (int64, error) {
+	return q.cmd.LLen(ctx, q.readyKey).Result()
+}
+
+// InFlight 返回已经取出但是还没有 ack 的消息数量
+func (q *Queue[T]) InFlight(ctx context.Context) (int64, error) {
+	return q.cmd.ZCard(ctx, q.processingKey).Result()
+}
+
+// DeadLen 返回死信列表的长度
+func (q *Queue[T]) DeadLen(ctx context.Context) (int64, error) {
+	return q.cmd.LLen(ctx, q.deadKey).Result()
+}
+
+// PopDead 从死信列表里取出一条消息，死信列表为空时返回 false
+func (q *Queue[T]) PopDead(ctx context.Context) (T, bool, error) {
+	data, err := q.cmd.LPop(ctx, q.deadKey).Bytes()
+	if err == redis.Nil {
+		return *new(T), false, nil
+	}
+	if err != nil {
+		return *new(T), false, err
+	}
+	v, err := q.codec.Decode(data)
+	if err != nil {
+		return *new(T), false, fmt.Errorf("decode dead message: %w", err)
+	}
+	return v, true, nil
+}
+
+func (q *Queue[T]) ack(ctx context.Context, id string, delivery int) error {
+	ok, err := q.cmd.Eval(ctx, luaQueueAck,
+		[]string{q.processingKey, q.msgsKey, q.deliveriesKey}, id, delivery).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrMessageExpired
+	}
+	return nil
+}
+
+func (q *Queue[T]) nack(ctx context.Context, id string, delivery int) error {
+	ok, err := q.cmd.Eval(ctx, luaQueueNack, q.keys(), id, delivery, q.maxDeliveries).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrMessageExpired
+	}
+	return nil
+}
+
+func (q *Queue[T]) keys() []string {
+	return []string{q.readyKey, q.msgsKey, q.deliveriesKey, q.processingKey, q.deadKey}
+}
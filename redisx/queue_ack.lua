@@ -0,0 +1,14 @@
+local processing = KEYS[1]
+local msgs = KEYS[2]
+local deliveries = KEYS[3]
+local id = ARGV[1]
+-- 投递次数用来区分同一条消息的不同投递
+local delivery = ARGV[2]
+
+-- 已经超时了，或者已经被重新投递给了别人
+if redis.call('HGET', deliveries, id) ~= delivery or redis.call('ZREM', processing, id) == 0 then
+    return 0
+end
+redis.call('HDEL', msgs, id)
+redis.call('HDEL', deliveries, id)
+return 1
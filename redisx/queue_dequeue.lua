@@ -0,0 +1,59 @@
+-- 可靠队列出队
+-- ready: 等待投递的消息 id 列表
+-- msgs: id => 消息内容
+-- deliveries: id => 投递次数
+-- processing: 已经投递但是还没有 ack 的消息，score 是可见性超时的截止时间
+-- dead: 死信列表，直接保存消息内容
+local ready = KEYS[1]
+local msgs = KEYS[2]
+local deliveries = KEYS[3]
+local processing = KEYS[4]
+local dead = KEYS[5]
+
+-- 可见性超时，毫秒
+local timeout = tonumber(ARGV[1])
+-- 最大投递次数，0 代表不限制
+local maxDeliveries = tonumber(ARGV[2])
+
+-- 用 Redis 的时间，不依赖各个客户端的时钟是一致的
+-- Redis 5 之前要先打开按命令复制，才能在 TIME 之后写数据
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local deadline = now + timeout
+
+-- 先把可见性超时的消息放回队首，或者投递次数用完了就进死信
+local expired = redis.call('ZRANGEBYSCORE', processing, '-inf', now, 'LIMIT', 0, 100)
+local requeue = {}
+for _, id in ipairs(expired) do
+    redis.call('ZREM', processing, id)
+    local cnt = tonumber(redis.call('HGET', deliveries, id) or '0')
+    if maxDeliveries > 0 and cnt >= maxDeliveries then
+        local payload = redis.call('HGET', msgs, id)
+        if payload then
+            redis.call('RPUSH', dead, payload)
+        end
+        redis.call('HDEL', msgs, id)
+        redis.call('HDEL', deliveries, id)
+    else
+        requeue[#requeue + 1] = id
+    end
+end
+-- 倒着 LPUSH，先超时的消息排在最前面
+for i = #requeue, 1, -1 do
+    redis.call('LPUSH', ready, requeue[i])
+end
+
+while true do
+    local id = redis.call('LPOP', ready)
+    if not id then
+        return false
+    end
+    local payload = redis.call('HGET', msgs, id)
+    -- 内容不在了就跳过
+    if payload then
+        local cnt = redis.call('HINCRBY', deliveries, id, 1)
+        redis.call('ZADD', processing, deadline, id)
+        return {id, payload, cnt}
+    end
+end
@@ -0,0 +1,25 @@
+local ready = KEYS[1]
+local msgs = KEYS[2]
+local deliveries = KEYS[3]
+local processing = KEYS[4]
+local dead = KEYS[5]
+
+local id = ARGV[1]
+local delivery = ARGV[2]
+local maxDeliveries = tonumber(ARGV[3])
+
+if redis.call('HGET', deliveries, id) ~= delivery or redis.call('ZREM', processing, id) == 0 then
+    return 0
+end
+local cnt = tonumber(delivery)
+if maxDeliveries > 0 and cnt >= maxDeliveries then
+    local payload = redis.call('HGET', msgs, id)
+    if payload then
+        redis.call('RPUSH', dead, payload)
+    end
+    redis.call('HDEL', msgs, id)
+    redis.call('HDEL', deliveries, id)
+else
+    redis.call('LPUSH', ready, id)
+end
+return 1
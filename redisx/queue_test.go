@@ -0,0 +1,165 @@
+package redisx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kirby980/go-pkg"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type queueEvent struct {
+	Uid int64 `json:"uid"`
+	Aid int64 `json:"aid"`
+}
+
+func newTestQueue(t *testing.T) *Queue[queueEvent] {
+	q, _ := newTestQueueWithRedis(t)
+	return q
+}
+
+func newTestQueueWithRedis(t *testing.T) (*Queue[queueEvent], *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return NewQueue[queueEvent](client, "test", pkg.JSONCodec[queueEvent]{}), mr
+}
+
+func TestQueue_EnqueueDequeue(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	_, ok, err := q.Peek(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for i := int64(1); i <= 3; i++ {
+		require.NoError(t, q.Enqueue(ctx, queueEvent{Uid: i, Aid: i * 10}))
+	}
+	n, err := q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	head, ok, err := q.Peek(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), head.Uid)
+
+	for i := int64(1); i <= 3; i++ {
+		msg, err := q.Dequeue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, i, msg.Value.Uid)
+		assert.Equal(t, 1, msg.Deliveries)
+		require.NoError(t, msg.Ack(ctx))
+		assert.ErrorIs(t, msg.Ack(ctx), ErrMessageExpired)
+	}
+	n, err = q.InFlight(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestQueue_DequeueTimeout(t *testing.T) {
+	q := newTestQueue(t).PollInterval(time.Millisecond * 10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = q.Enqueue(context.Background(), queueEvent{Uid: 9})
+	}()
+	msg, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), msg.Value.Uid)
+}
+
+func TestQueue_Redelivery(t *testing.T) {
+	q := newTestQueue(t).VisibilityTimeout(time.Millisecond * 50).MaxDeliveries(2)
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, queueEvent{Uid: 1}))
+
+	msg, err := q.TryDequeue(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	// 还没超时，不会重新投递
+	again, err := q.TryDequeue(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, again)
+
+	time.Sleep(time.Millisecond * 60)
+	again, err = q.TryDequeue(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, again)
+	assert.Equal(t, msg.ID, again.ID)
+	assert.Equal(t, 2, again.Deliveries)
+	// 第一次投递已经超时了，ack 失败
+	assert.ErrorIs(t, msg.Ack(ctx), ErrMessageExpired)
+
+	// 投递次数用完，进入死信
+	time.Sleep(time.Millisecond * 60)
+	last, err := q.TryDequeue(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, last)
+	n, err := q.DeadLen(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	dead, ok, err := q.PopDead(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), dead.Uid)
+}
+
+func TestQueue_RedeliveryServerTime(t *testing.T) {
+	q, mr := newTestQueueWithRedis(t)
+	q.VisibilityTimeout(time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+	for i := int64(1); i <= 3; i++ {
+		mr.SetTime(now.Add(time.Duration(i) * time.Millisecond))
+		require.NoError(t, q.Enqueue(ctx, queueEvent{Uid: i}))
+		msg, err := q.TryDequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, msg)
+	}
+
+	// 只有 redis 的时钟往前走了，超时的消息按照原来的顺序重新投递
+	mr.SetTime(now.Add(time.Minute * 2))
+	for i := int64(1); i <= 3; i++ {
+		msg, err := q.TryDequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, msg)
+		assert.Equal(t, i, msg.Value.Uid)
+		assert.Equal(t, 2, msg.Deliveries)
+	}
+}
+
+func TestQueue_Nack(t *testing.T) {
+	q := newTestQueue(t).MaxDeliveries(2)
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, queueEvent{Uid: 1}))
+	require.NoError(t, q.Enqueue(ctx, queueEvent{Uid: 2}))
+
+	msg, err := q.TryDequeue(ctx)
+	require.NoError(t, err)
+	require.NoError(t, msg.Nack(ctx))
+	// Nack 之后放回队首
+	msg, err = q.TryDequeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), msg.Value.Uid)
+	assert.Equal(t, 2, msg.Deliveries)
+	require.NoError(t, msg.Nack(ctx))
+
+	n, err := q.DeadLen(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	msg, err = q.TryDequeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), msg.Value.Uid)
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import "sync/atomic"
+
+// RingBuffer 固定容量的无锁多生产者多消费者环形队列
+// 每个槽位带一个序列号，生产者和消费者通过 CAS 抢占 tail/head，
+// 再根据槽位的序列号判断槽位是否可写、可读
+// 容量会向上取整到 2 的幂，满了 Enqueue 返回 false，不会扩容
+type RingBuffer[T any] struct {
+	_    [cacheLinePad]byte
+	head atomic.Uint64
+	_    [cacheLinePad]byte
+	tail atomic.Uint64
+	_    [cacheLinePad]byte
+	mask uint64
+	// 槽位
+	cells []ringCell[T]
+}
+
+// cacheLinePad 用于把 head 和 tail 隔开，避免伪共享
+const cacheLinePad = 64
+
+type ringCell[T any] struct {
+	seq atomic.Uint64
+	val T
+}
+
+// NewRingBuffer 创建一个环形队列，capacity 会向上取整到 2 的幂，最小为 2
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	size := uint64(2)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	cells := make([]ringCell[T], size)
+	for i := range cells {
+		cells[i].seq.Store(uint64(i))
+	}
+	return &RingBuffer[T]{
+		mask:  size - 1,
+		cells: cells,
+	}
+}
+
+// Enqueue 入队，队列满时返回 false
+func (r *RingBuffer[T]) Enqueue(v T) bool {
+	pos := r.tail.Load()
+	for {
+		cell := &r.cells[pos&r.mask]
+		seq := cell.seq.Load()
+		diff := int64(seq) - int64(pos)
+		switch {
+		case diff == 0:
+			// 槽位可写，抢占 tail
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				cell.val = v
+				cell.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			// 槽位还没有被消费，队列满了
+			return false
+		default:
+			// 被别的生产者抢先了
+			pos = r.tail.Load()
+		}
+	}
+}
+
+// Dequeue 出队，队列空时返回零值和 false
+func (r *RingBuffer[T]) Dequeue() (T, bool) {
+	pos := r.head.Load()
+	for {
+		cell := &r.cells[pos&r.mask]
+		seq := cell.seq.Load()
+		diff := int64(seq) - int64(pos+1)
+		switch {
+		case diff == 0:
+			// 槽位可读，抢占 head
+			if r.head.CompareAndSwap(pos, pos+1) {
+				v := cell.val
+				cell.val = *new(T)
+				// 留给下一轮的生产者
+				cell.seq.Store(pos + r.mask + 1)
+				return v, true
+			}
+			pos = r.head.Load()
+		case diff < 0:
+			// 槽位还没有被写入，队列空了
+			return *new(T), false
+		default:
+			// 被别的消费者抢先了
+			pos = r.head.Load()
+		}
+	}
+}
+
+// Len 返回队列长度
+// 并发读写时只是一个近似值
+func (r *RingBuffer[T]) Len() int {
+	head := r.head.Load()
+	tail := r.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	n := int(tail - head)
+	if n > r.Cap() {
+		return r.Cap()
+	}
+	return n
+}
+
+// Cap 返回队列容量
+func (r *RingBuffer[T]) Cap() int {
+	return int(r.mask + 1)
+}
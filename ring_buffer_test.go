@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRingBuffer[int](3)
+	// 向上取整到 4
+	require.Equal(t, 4, r.Cap())
+	for i := 0; i < 4; i++ {
+		require.True(t, r.Enqueue(i))
+	}
+	require.False(t, r.Enqueue(4))
+	assert.Equal(t, 4, r.Len())
+
+	// 多转几圈，验证序列号复用
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 4; i++ {
+			v, ok := r.Dequeue()
+			require.True(t, ok)
+			assert.Equal(t, round*4+i, v)
+		}
+		_, ok := r.Dequeue()
+		require.False(t, ok)
+		for i := 0; i < 4; i++ {
+			require.True(t, r.Enqueue((round+1)*4+i))
+		}
+	}
+}
+
+func TestRingBuffer_MPMC(t *testing.T) {
+	const (
+		producers = 4
+		consumers = 4
+		perWorker = 2000
+	)
+	r := NewRingBuffer[int64](64)
+	var sum atomic.Int64
+	var consumed atomic.Int64
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= perWorker; i++ {
+				for !r.Enqueue(int64(i)) {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for consumed.Load() < producers*perWorker {
+				if v, ok := r.Dequeue(); ok {
+					sum.Add(v)
+					consumed.Add(1)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(producers*perWorker*(perWorker+1)/2), sum.Load())
+	assert.Equal(t, 0, r.Len())
+}
+
+func BenchmarkQueue(b *testing.B) {
+	b.Run("Queue", func(b *testing.B) {
+		var q Queue[int]
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+				q.Dequeue()
+			}
+		})
+	})
+	b.Run("RrQueue", func(b *testing.B) {
+		var q RrQueue[int]
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+				q.Dequeue()
+			}
+		})
+	})
+	b.Run("RingBuffer", func(b *testing.B) {
+		r := NewRingBuffer[int](1024)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				r.Enqueue(1)
+				r.Dequeue()
+			}
+		})
+	})
+}
@@ -1,6 +1,9 @@
 package pkg
 
-import "sync"
+import (
+	"iter"
+	"sync"
+)
 
 type RrQueue[T any] struct {
 	// 环形队列
@@ -9,7 +12,7 @@ type RrQueue[T any] struct {
 	head int
 	// 队尾
 	tail int
-	// 队列大小
+	// 元素个数，容量是 len(items)
 	size int
 	// 互斥锁
 	mu sync.Mutex
@@ -23,29 +26,29 @@ func (q *RrQueue[T]) Enqueue(v T) {
 		q.resize()
 	}
 	q.items[q.tail] = v
-	q.tail = (q.tail + 1) % q.size
+	q.tail = (q.tail + 1) % len(q.items)
 	q.size++
 }
 
 // resize 扩容
-// size为0改为10，size大小小于256就扩大两倍 否则扩大1.25倍
+// 容量为0改为10，容量小于256就扩大两倍 否则扩大1.25倍
 func (q *RrQueue[T]) resize() {
-	var newSize int
-	if q.size == 0 {
-		newSize = 10
-	} else if q.size < 256 {
-		newSize = q.size * 2
+	oldCap := len(q.items)
+	var newCap int
+	if oldCap == 0 {
+		newCap = 10
+	} else if oldCap < 256 {
+		newCap = oldCap * 2
 	} else {
-		newSize = q.size * 5 / 4
+		newCap = oldCap * 5 / 4
 	}
-	newItems := make([]T, newSize)
+	newItems := make([]T, newCap)
 	for i := 0; i < q.size; i++ {
-		newItems[i] = q.items[(q.head+i)%q.size]
+		newItems[i] = q.items[(q.head+i)%oldCap]
 	}
 	q.items = newItems
 	q.head = 0
 	q.tail = q.size
-	q.size = newSize
 }
 
 // Dequeue 出队
@@ -56,7 +59,8 @@ func (q *RrQueue[T]) Dequeue() T {
 		return *new(T)
 	}
 	v := q.items[q.head]
-	q.head = (q.head + 1) % q.size
+	q.items[q.head] = *new(T)
+	q.head = (q.head + 1) % len(q.items)
 	q.size--
 	return v
 }
@@ -87,3 +91,21 @@ func (q *RrQueue[T]) Clear() {
 	q.tail = 0
 	q.size = 0
 }
+
+// All 返回一个从队首到队尾的迭代器
+// 遍历的是调用时的快照，遍历过程中可以安全地修改队列
+func (q *RrQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		q.mu.Lock()
+		items := make([]T, q.size)
+		for i := 0; i < q.size; i++ {
+			items[i] = q.items[(q.head+i)%len(q.items)]
+		}
+		q.mu.Unlock()
+		for _, v := range items {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
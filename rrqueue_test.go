@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRrQueue_WrapAround(t *testing.T) {
+	var q RrQueue[int]
+	next, want := 0, 0
+	// 交替入队出队，让 head/tail 绕过数组末尾并触发扩容
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 7; i++ {
+			q.Enqueue(next)
+			next++
+		}
+		for i := 0; i < 5; i++ {
+			require.Equal(t, want, q.Dequeue())
+			want++
+		}
+	}
+	require.Equal(t, next-want, q.Len())
+	for q.Len() > 0 {
+		require.Equal(t, want, q.Dequeue())
+		want++
+	}
+}
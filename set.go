@@ -0,0 +1,231 @@
+package pkg
+
+import (
+	"iter"
+	"sync"
+)
+
+// Set 集合，非并发安全，零值可以直接使用
+// 并发场景使用 SyncSet
+type Set[T comparable] struct {
+	m map[T]struct{}
+}
+
+// NewSet 创建集合，并放入 vals
+func NewSet[T comparable](vals ...T) *Set[T] {
+	s := &Set[T]{m: make(map[T]struct{}, len(vals))}
+	s.Add(vals...)
+	return s
+}
+
+// Add 添加元素
+func (s *Set[T]) Add(vals ...T) {
+	if s.m == nil {
+		s.m = make(map[T]struct{}, len(vals))
+	}
+	for _, v := range vals {
+		s.m[v] = struct{}{}
+	}
+}
+
+// Remove 删除元素
+func (s *Set[T]) Remove(vals ...T) {
+	for _, v := range vals {
+		delete(s.m, v)
+	}
+}
+
+// Contains 判断元素是否存在
+func (s *Set[T]) Contains(v T) bool {
+	_, ok := s.m[v]
+	return ok
+}
+
+// Len 返回元素个数
+func (s *Set[T]) Len() int {
+	return len(s.m)
+}
+
+// Clear 清空集合
+func (s *Set[T]) Clear() {
+	clear(s.m)
+}
+
+// Clone 复制一个新的集合
+func (s *Set[T]) Clone() *Set[T] {
+	res := &Set[T]{m: make(map[T]struct{}, len(s.m))}
+	for v := range s.m {
+		res.m[v] = struct{}{}
+	}
+	return res
+}
+
+// Union 并集
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	res := s.Clone()
+	for v := range other.m {
+		res.m[v] = struct{}{}
+	}
+	return res
+}
+
+// Intersect 交集
+func (s *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	small, big := s, other
+	if small.Len() > big.Len() {
+		small, big = big, small
+	}
+	res := &Set[T]{m: make(map[T]struct{}, small.Len())}
+	for v := range small.m {
+		if big.Contains(v) {
+			res.m[v] = struct{}{}
+		}
+	}
+	return res
+}
+
+// Difference 差集，在 s 中但是不在 other 中的元素
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	res := &Set[T]{m: make(map[T]struct{}, s.Len())}
+	for v := range s.m {
+		if !other.Contains(v) {
+			res.m[v] = struct{}{}
+		}
+	}
+	return res
+}
+
+// SymmetricDifference 对称差集，只在其中一个集合中出现的元素
+func (s *Set[T]) SymmetricDifference(other *Set[T]) *Set[T] {
+	res := s.Difference(other)
+	for v := range other.m {
+		if !s.Contains(v) {
+			res.m[v] = struct{}{}
+		}
+	}
+	return res
+}
+
+// All 返回集合的迭代器，顺序不固定
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s.m {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ToSlice 转换为切片，顺序不固定
+func (s *Set[T]) ToSlice() []T {
+	res := make([]T, 0, len(s.m))
+	for v := range s.m {
+		res = append(res, v)
+	}
+	return res
+}
+
+// SyncSet 并发安全的集合，零值可以直接使用
+type SyncSet[T comparable] struct {
+	mu  sync.RWMutex
+	set Set[T]
+}
+
+// NewSyncSet 创建并发安全的集合，并放入 vals
+func NewSyncSet[T comparable](vals ...T) *SyncSet[T] {
+	return &SyncSet[T]{set: *NewSet(vals...)}
+}
+
+// Add 添加元素
+func (s *SyncSet[T]) Add(vals ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Add(vals...)
+}
+
+// Remove 删除元素
+func (s *SyncSet[T]) Remove(vals ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Remove(vals...)
+}
+
+// Contains 判断元素是否存在
+func (s *SyncSet[T]) Contains(v T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Contains(v)
+}
+
+// Len 返回元素个数
+func (s *SyncSet[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Len()
+}
+
+// Clear 清空集合
+func (s *SyncSet[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Clear()
+}
+
+// Snapshot 返回当前内容的非并发安全副本
+func (s *SyncSet[T]) Snapshot() *Set[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Clone()
+}
+
+// Union 并集
+func (s *SyncSet[T]) Union(other *SyncSet[T]) *SyncSet[T] {
+	// 先拿 other 的快照，避免同时持有两把锁导致死锁
+	o := other.Snapshot()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &SyncSet[T]{set: *s.set.Union(o)}
+}
+
+// Intersect 交集
+func (s *SyncSet[T]) Intersect(other *SyncSet[T]) *SyncSet[T] {
+	o := other.Snapshot()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &SyncSet[T]{set: *s.set.Intersect(o)}
+}
+
+// Difference 差集，在 s 中但是不在 other 中的元素
+func (s *SyncSet[T]) Difference(other *SyncSet[T]) *SyncSet[T] {
+	o := other.Snapshot()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &SyncSet[T]{set: *s.set.Difference(o)}
+}
+
+// SymmetricDifference 对称差集，只在其中一个集合中出现的元素
+func (s *SyncSet[T]) SymmetricDifference(other *SyncSet[T]) *SyncSet[T] {
+	o := other.Snapshot()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &SyncSet[T]{set: *s.set.SymmetricDifference(o)}
+}
+
+// All 返回集合的迭代器，遍历的是调用时的快照
+func (s *SyncSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s.Snapshot().m {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ToSlice 转换为切片，顺序不固定
+func (s *SyncSet[T]) ToSlice() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.ToSlice()
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	a := NewSet(1, 2, 3, 4)
+	b := NewSet(3, 4, 5)
+
+	assert.True(t, a.Contains(1))
+	assert.False(t, a.Contains(5))
+	assert.ElementsMatch(t, []int{1, 2, 3, 4, 5}, a.Union(b).ToSlice())
+	assert.ElementsMatch(t, []int{3, 4}, a.Intersect(b).ToSlice())
+	assert.ElementsMatch(t, []int{1, 2}, a.Difference(b).ToSlice())
+	assert.ElementsMatch(t, []int{1, 2, 5}, a.SymmetricDifference(b).ToSlice())
+	// 集合运算不会修改原集合
+	assert.Equal(t, 4, a.Len())
+	assert.Equal(t, 3, b.Len())
+
+	a.Remove(1, 2)
+	assert.ElementsMatch(t, []int{3, 4}, slices.Collect(a.All()))
+}
+
+func TestSyncSet(t *testing.T) {
+	s := NewSyncSet[int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Add(base*100 + j)
+				s.Contains(j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, s.Len())
+
+	other := NewSyncSet(0, 1, 5000)
+	assert.ElementsMatch(t, []int{0, 1}, s.Intersect(other).ToSlice())
+	assert.ElementsMatch(t, []int{5000}, other.Difference(s).ToSlice())
+	assert.Equal(t, 1001, s.Union(other).Len())
+	assert.Equal(t, 999, s.SymmetricDifference(other).Len())
+}
+
+func TestSet_ZeroValue(t *testing.T) {
+	var s Set[int]
+	assert.False(t, s.Contains(1))
+	s.Remove(1)
+	s.Add(1, 2)
+	assert.ElementsMatch(t, []int{1, 2}, s.ToSlice())
+
+	var ss SyncSet[int]
+	assert.Equal(t, 0, ss.Len())
+	ss.Add(1)
+	assert.True(t, ss.Contains(1))
+	assert.ElementsMatch(t, []int{1, 2}, ss.Union(NewSyncSet(2)).ToSlice())
+}
+
+func TestOrderedMap(t *testing.T) {
+	om := NewOrderedMap[string, int]()
+	om.Set("c", 1)
+	om.Set("a", 2)
+	om.Set("b", 3)
+	// 更新不改变位置
+	om.Set("c", 4)
+	assert.Equal(t, []string{"c", "a", "b"}, om.Keys())
+	assert.Equal(t, []int{4, 2, 3}, om.Values())
+
+	v, ok := om.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	om.Delete("a")
+	_, ok = om.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, om.Len())
+	om.Set("a", 5)
+	assert.Equal(t, []string{"c", "b", "a"}, om.Keys())
+}
+
+func TestSliceDiffSet(t *testing.T) {
+	src := []int64{9, 3, 7, 1, 3, 5, 8}
+	dst := []int64{1, 8}
+	// 多跑几次，结果顺序是固定的
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, []int64{9, 3, 7, 5}, SliceDiffSet(src, dst))
+	}
+}